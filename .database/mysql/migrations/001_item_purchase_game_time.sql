@@ -0,0 +1,11 @@
+-- Adds game time to item purchases stored before it was recorded.
+--
+-- Game time is worked out from when each game started, pauses weren't recorded
+-- for these replays so they can't be taken out. Purchases without a stored
+-- replay_info row are left at 0.
+
+ALTER TABLE `item_purchase` ADD COLUMN `gameTime` float NOT NULL DEFAULT '0' AFTER `timestamp`;
+
+UPDATE `item_purchase` p
+  JOIN `replay_info` r ON r.`gameId` = p.`gameId`
+  SET p.`gameTime` = p.`timestamp` - r.`gameStart`;
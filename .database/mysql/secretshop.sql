@@ -27,7 +27,10 @@ CREATE TABLE `item_purchase` (
   `steamId` bigint(20) NOT NULL,
   `hero` varchar(255) NOT NULL,
   `item` varchar(255) NOT NULL,
-  `timestamp` float NOT NULL
+  `timestamp` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  `nearestFight` int(11) NOT NULL DEFAULT '0',
+  `fightOffset` float NOT NULL DEFAULT '0'
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1;
 /*!40101 SET character_set_client = @saved_cs_client */;
 
@@ -79,6 +82,14 @@ CREATE TABLE `replay_info` (
   `players` varchar(2048) NOT NULL,
   `heroes` varchar(2048) NOT NULL,
   `friendlyName` varchar(2048) DEFAULT NULL,
+  `gameMode` int(11) NOT NULL DEFAULT '0',
+  `winner` int(11) NOT NULL DEFAULT '0',
+  `leagueId` int(10) unsigned NOT NULL DEFAULT '0',
+  `radiantTeamId` int(10) unsigned NOT NULL DEFAULT '0',
+  `direTeamId` int(10) unsigned NOT NULL DEFAULT '0',
+  `radiantTeamTag` varchar(255) NOT NULL DEFAULT '',
+  `direTeamTag` varchar(255) NOT NULL DEFAULT '',
+  `endTime` int(10) unsigned NOT NULL DEFAULT '0',
   PRIMARY KEY (`gameId`)
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -91,6 +102,605 @@ LOCK TABLES `replay_info` WRITE;
 /*!40000 ALTER TABLE `replay_info` DISABLE KEYS */;
 /*!40000 ALTER TABLE `replay_info` ENABLE KEYS */;
 UNLOCK TABLES;
+
+--
+-- Table structure for table `replay_job`
+--
+
+DROP TABLE IF EXISTS `replay_job`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `replay_job` (
+  `id` varchar(32) NOT NULL,
+  `fileName` varchar(1023) NOT NULL,
+  `path` varchar(1023) NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `gameId` bigint(20) NOT NULL DEFAULT '0',
+  `modules` varchar(1023) NOT NULL DEFAULT '',
+  `error` text NOT NULL,
+  `created` datetime NOT NULL,
+  `updated` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `replay_job`
+--
+
+LOCK TABLES `replay_job` WRITE;
+/*!40000 ALTER TABLE `replay_job` DISABLE KEYS */;
+/*!40000 ALTER TABLE `replay_job` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `kill_event`
+--
+
+DROP TABLE IF EXISTS `kill_event`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `kill_event` (
+  `gameId` bigint(20) NOT NULL,
+  `killer` varchar(255) NOT NULL,
+  `killerSteamId` bigint(20) NOT NULL,
+  `victim` varchar(255) NOT NULL,
+  `victimSteamId` bigint(20) NOT NULL,
+  `assisters` varchar(1023) NOT NULL,
+  `timestamp` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  `buybackEligible` tinyint(1) NOT NULL,
+  `goldLost` int(11) NOT NULL,
+  `deathDuration` float NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `kill_event`
+--
+
+LOCK TABLES `kill_event` WRITE;
+/*!40000 ALTER TABLE `kill_event` DISABLE KEYS */;
+/*!40000 ALTER TABLE `kill_event` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `item_lifecycle`
+--
+
+DROP TABLE IF EXISTS `item_lifecycle`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `item_lifecycle` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `item` varchar(255) NOT NULL,
+  `acquired` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  `uses` int(11) NOT NULL,
+  `removed` float NOT NULL,
+  `removedBy` varchar(16) NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `item_lifecycle`
+--
+
+LOCK TABLES `item_lifecycle` WRITE;
+/*!40000 ALTER TABLE `item_lifecycle` DISABLE KEYS */;
+/*!40000 ALTER TABLE `item_lifecycle` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `item_assembly`
+--
+
+DROP TABLE IF EXISTS `item_assembly`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `item_assembly` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `item` varchar(255) NOT NULL,
+  `timestamp` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  `components` varchar(1023) NOT NULL,
+  KEY `gameId` (`gameId`),
+  KEY `item` (`item`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `item_assembly`
+--
+
+LOCK TABLES `item_assembly` WRITE;
+/*!40000 ALTER TABLE `item_assembly` DISABLE KEYS */;
+/*!40000 ALTER TABLE `item_assembly` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `timeline`
+--
+
+DROP TABLE IF EXISTS `timeline`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `timeline` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `timestamp` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  `gold` int(11) NOT NULL,
+  `netWorth` int(11) NOT NULL,
+  `xp` int(11) NOT NULL,
+  `level` int(11) NOT NULL,
+  `lastHits` int(11) NOT NULL,
+  `denies` int(11) NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `timeline`
+--
+
+LOCK TABLES `timeline` WRITE;
+/*!40000 ALTER TABLE `timeline` DISABLE KEYS */;
+/*!40000 ALTER TABLE `timeline` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `draft_selection`
+--
+
+DROP TABLE IF EXISTS `draft_selection`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `draft_selection` (
+  `gameId` bigint(20) NOT NULL,
+  `pickOrder` int(11) NOT NULL,
+  `isPick` tinyint(1) NOT NULL,
+  `team` int(10) unsigned NOT NULL,
+  `heroId` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`gameId`,`pickOrder`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `draft_selection`
+--
+
+LOCK TABLES `draft_selection` WRITE;
+/*!40000 ALTER TABLE `draft_selection` DISABLE KEYS */;
+/*!40000 ALTER TABLE `draft_selection` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `player_slot`
+--
+
+DROP TABLE IF EXISTS `player_slot`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `player_slot` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `playerId` int(11) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `side` varchar(16) NOT NULL,
+  `teamId` int(10) unsigned NOT NULL,
+  `teamTag` varchar(255) NOT NULL,
+  `teamName` varchar(1023) NOT NULL,
+  PRIMARY KEY (`gameId`,`playerId`),
+  KEY `steamId` (`steamId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `player_slot`
+--
+
+LOCK TABLES `player_slot` WRITE;
+/*!40000 ALTER TABLE `player_slot` DISABLE KEYS */;
+/*!40000 ALTER TABLE `player_slot` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `skill_build`
+--
+
+DROP TABLE IF EXISTS `skill_build`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `skill_build` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `skillOrder` int(11) NOT NULL,
+  `ability` varchar(255) NOT NULL,
+  `abilityLevel` int(11) NOT NULL,
+  `heroLevel` int(11) NOT NULL,
+  `timestamp` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `skill_build`
+--
+
+LOCK TABLES `skill_build` WRITE;
+/*!40000 ALTER TABLE `skill_build` DISABLE KEYS */;
+/*!40000 ALTER TABLE `skill_build` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `ward`
+--
+
+DROP TABLE IF EXISTS `ward`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `ward` (
+  `gameId` bigint(20) NOT NULL,
+  `type` varchar(16) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `team` int(11) NOT NULL,
+  `x` float NOT NULL,
+  `y` float NOT NULL,
+  `placed` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  `removed` float NOT NULL,
+  `lifetime` float NOT NULL,
+  `killedBy` varchar(255) NOT NULL,
+  `dewarded` tinyint(1) NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `ward`
+--
+
+LOCK TABLES `ward` WRITE;
+/*!40000 ALTER TABLE `ward` DISABLE KEYS */;
+/*!40000 ALTER TABLE `ward` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `hero_path`
+--
+
+DROP TABLE IF EXISTS `hero_path`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `hero_path` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `points` mediumblob NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `hero_path`
+--
+
+LOCK TABLES `hero_path` WRITE;
+/*!40000 ALTER TABLE `hero_path` DISABLE KEYS */;
+/*!40000 ALTER TABLE `hero_path` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `objective_event`
+--
+
+DROP TABLE IF EXISTS `objective_event`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `objective_event` (
+  `gameId` bigint(20) NOT NULL,
+  `type` varchar(32) NOT NULL,
+  `target` varchar(255) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `team` int(11) NOT NULL,
+  `timestamp` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `objective_event`
+--
+
+LOCK TABLES `objective_event` WRITE;
+/*!40000 ALTER TABLE `objective_event` DISABLE KEYS */;
+/*!40000 ALTER TABLE `objective_event` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `rune_event`
+--
+
+DROP TABLE IF EXISTS `rune_event`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `rune_event` (
+  `gameId` bigint(20) NOT NULL,
+  `event` varchar(16) NOT NULL,
+  `rune` varchar(32) NOT NULL,
+  `runeType` int(11) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `x` float NOT NULL,
+  `y` float NOT NULL,
+  `timestamp` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `rune_event`
+--
+
+LOCK TABLES `rune_event` WRITE;
+/*!40000 ALTER TABLE `rune_event` DISABLE KEYS */;
+/*!40000 ALTER TABLE `rune_event` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `buyback_event`
+--
+
+DROP TABLE IF EXISTS `buyback_event`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `buyback_event` (
+  `gameId` bigint(20) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `timestamp` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  `cost` int(11) NOT NULL,
+  `goldAfter` int(11) NOT NULL,
+  `nextItem` varchar(255) NOT NULL,
+  `nextItemCost` int(11) NOT NULL,
+  `couldAffordNextItem` tinyint(1) NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `buyback_event`
+--
+
+LOCK TABLES `buyback_event` WRITE;
+/*!40000 ALTER TABLE `buyback_event` DISABLE KEYS */;
+/*!40000 ALTER TABLE `buyback_event` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `gold_spending`
+--
+
+DROP TABLE IF EXISTS `gold_spending`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `gold_spending` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `items` int(11) NOT NULL,
+  `consumables` int(11) NOT NULL,
+  `buybacks` int(11) NOT NULL,
+  `deaths` int(11) NOT NULL,
+  `total` int(11) NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `gold_spending`
+--
+
+LOCK TABLES `gold_spending` WRITE;
+/*!40000 ALTER TABLE `gold_spending` DISABLE KEYS */;
+/*!40000 ALTER TABLE `gold_spending` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `damage`
+--
+
+DROP TABLE IF EXISTS `damage`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `damage` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `kind` varchar(16) NOT NULL,
+  `inflictor` varchar(255) NOT NULL,
+  `target` varchar(255) NOT NULL,
+  `amount` bigint(20) NOT NULL,
+  `hits` int(11) NOT NULL,
+  KEY `gameId` (`gameId`),
+  KEY `inflictor` (`inflictor`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `damage`
+--
+
+LOCK TABLES `damage` WRITE;
+/*!40000 ALTER TABLE `damage` DISABLE KEYS */;
+/*!40000 ALTER TABLE `damage` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `teamfight`
+--
+
+DROP TABLE IF EXISTS `teamfight`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `teamfight` (
+  `gameId` bigint(20) NOT NULL,
+  `number` int(11) NOT NULL,
+  `start` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  `end` float NOT NULL,
+  `participants` varchar(1023) NOT NULL,
+  `deaths` varchar(1023) NOT NULL,
+  `radiantDeaths` int(11) NOT NULL,
+  `direDeaths` int(11) NOT NULL,
+  `goldSwing` int(11) NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `teamfight`
+--
+
+LOCK TABLES `teamfight` WRITE;
+/*!40000 ALTER TABLE `teamfight` DISABLE KEYS */;
+/*!40000 ALTER TABLE `teamfight` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `chat_message`
+--
+
+DROP TABLE IF EXISTS `chat_message`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `chat_message` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `sender` varchar(255) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `channel` varchar(16) NOT NULL,
+  `message` varchar(1023) NOT NULL,
+  `wheelId` int(11) NOT NULL,
+  `timestamp` float NOT NULL,
+  `gameTime` float NOT NULL DEFAULT '0',
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `chat_message`
+--
+
+LOCK TABLES `chat_message` WRITE;
+/*!40000 ALTER TABLE `chat_message` DISABLE KEYS */;
+/*!40000 ALTER TABLE `chat_message` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `replay_pause`
+--
+
+DROP TABLE IF EXISTS `replay_pause`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `replay_pause` (
+  `gameId` bigint(20) NOT NULL,
+  `start` float NOT NULL,
+  `end` float NOT NULL,
+  `gameTime` float NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `replay_pause`
+--
+
+LOCK TABLES `replay_pause` WRITE;
+/*!40000 ALTER TABLE `replay_pause` DISABLE KEYS */;
+/*!40000 ALTER TABLE `replay_pause` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `item_delivery`
+--
+
+DROP TABLE IF EXISTS `item_delivery`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `item_delivery` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `item` varchar(255) NOT NULL,
+  `purchased` float NOT NULL,
+  `gameTime` float NOT NULL,
+  `stashed` tinyint(1) NOT NULL,
+  `pickedUp` float NOT NULL,
+  `delivered` float NOT NULL,
+  `latency` float NOT NULL,
+  `byCourier` tinyint(1) NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `item_delivery`
+--
+
+LOCK TABLES `item_delivery` WRITE;
+/*!40000 ALTER TABLE `item_delivery` DISABLE KEYS */;
+/*!40000 ALTER TABLE `item_delivery` ENABLE KEYS */;
+UNLOCK TABLES;
+
+--
+-- Table structure for table `inventory_snapshot`
+--
+
+DROP TABLE IF EXISTS `inventory_snapshot`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `inventory_snapshot` (
+  `gameId` bigint(20) NOT NULL,
+  `steamId` bigint(20) NOT NULL,
+  `hero` varchar(255) NOT NULL,
+  `reason` varchar(16) NOT NULL,
+  `timestamp` float NOT NULL,
+  `gameTime` float NOT NULL,
+  `inventory` varchar(1023) NOT NULL,
+  `backpack` varchar(1023) NOT NULL,
+  `stash` varchar(1023) NOT NULL,
+  `teleport` varchar(255) NOT NULL,
+  `neutral` varchar(255) NOT NULL,
+  KEY `gameId` (`gameId`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `inventory_snapshot`
+--
+
+LOCK TABLES `inventory_snapshot` WRITE;
+/*!40000 ALTER TABLE `inventory_snapshot` DISABLE KEYS */;
+/*!40000 ALTER TABLE `inventory_snapshot` ENABLE KEYS */;
+UNLOCK TABLES;
 /*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
 
 /*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
@@ -4,15 +4,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
-	"os"
 
 	"strconv"
+	"strings"
 
 	"encoding/json"
 
-	"strings"
-
 	"github.com/gorilla/mux"
 	"github.com/oliread/secretshop"
 )
@@ -20,164 +19,176 @@ import (
 // Handler contains information about a API http handler
 type Handler struct {
 	conf   secretshop.Config
+	queue  *secretshop.Queue
 	Router *mux.Router
 }
 
 // NewHandler creates a new http handler for an API instance
-func NewHandler(conf secretshop.Config) (h Handler, err error) {
+func NewHandler(conf secretshop.Config, queue *secretshop.Queue) (h Handler, err error) {
 	h = Handler{
-		conf: conf,
+		conf:  conf,
+		queue: queue,
 	}
 
 	h.Router = mux.NewRouter()
 	h.Router.Handle("/replay/upload", h.isAuthenticated(http.HandlerFunc(h.replayNewPost))).Methods("POST")
 	h.Router.Handle("/replay/friendlyname", h.isAuthenticated(http.HandlerFunc(h.replayFriendlyNamePost))).Methods("POST")
 
+	h.Router.HandleFunc("/replay/jobs/{id}", h.replayJobGet).Methods("GET")
 	h.Router.HandleFunc("/replay/info", h.replayInfoGet).Methods("GET")
 	h.Router.HandleFunc("/replay/items", h.itemPurchaseGet).Methods("GET")
+	h.Router.HandleFunc("/replay/items/lifecycle", h.itemLifecycleGet).Methods("GET")
+	h.Router.HandleFunc("/replay/items/assembly", h.itemAssemblyGet).Methods("GET")
+	h.Router.HandleFunc("/replay/items/timings", h.itemTimingGet).Methods("GET")
+	h.Router.HandleFunc("/replay/players", h.playerSlotGet).Methods("GET")
+	h.Router.HandleFunc("/replay/skills", h.skillBuildGet).Methods("GET")
+	h.Router.HandleFunc("/replay/kills", h.killEventGet).Methods("GET")
+	h.Router.HandleFunc("/replay/timeline", h.timelineGet).Methods("GET")
+	h.Router.HandleFunc("/replay/wards", h.wardGet).Methods("GET")
+	h.Router.HandleFunc("/replay/positions", h.positionGet).Methods("GET")
+	h.Router.HandleFunc("/replay/heatmap", h.heatmapGet).Methods("GET")
+	h.Router.HandleFunc("/replay/objectives", h.objectiveEventGet).Methods("GET")
+	h.Router.HandleFunc("/replay/runes", h.runeEventGet).Methods("GET")
+	h.Router.HandleFunc("/replay/buybacks", h.buybackEventGet).Methods("GET")
+	h.Router.HandleFunc("/replay/spending", h.goldSpendingGet).Methods("GET")
+	h.Router.HandleFunc("/replay/damage", h.damageGet).Methods("GET")
+	h.Router.HandleFunc("/replay/fights", h.teamfightGet).Methods("GET")
+	h.Router.HandleFunc("/replay/chat", h.chatMessageGet).Methods("GET")
+	h.Router.HandleFunc("/replay/deliveries", h.itemDeliveryGet).Methods("GET")
+	h.Router.HandleFunc("/replay/inventory", h.inventoryGet).Methods("GET")
 	h.Router.HandleFunc("/player/info", h.playerInfoGet).Methods("GET")
 
 	return h, nil
 }
 
 func (h *Handler) replayNewPost(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(1024 * 1024 * 300); err != nil {
-		log.Printf("Error uploading replay: %s", err)
-		w.WriteHeader(400)
-		w.Write([]byte(err.Error()))
-		return
+	var modules []string
+	if value := r.URL.Query().Get("modules"); value != "" {
+		modules = strings.Split(value, ",")
+		if err := secretshop.CheckModules(modules); err != nil {
+			log.Printf("Error uploading replay: %s", err)
+			w.WriteHeader(400)
+			w.Write([]byte(fmt.Sprintf("Error uploading replay: %s", err)))
+			return
+		}
 	}
 
-	file, handler, err := r.FormFile("replay")
-	f, err := os.OpenFile("/tmp/"+handler.Filename, os.O_WRONLY|os.O_CREATE, 0666)
+	r.Body = http.MaxBytesReader(w, r.Body, 1024*1024*300)
+	reader, err := r.MultipartReader()
 	if err != nil {
-		w.WriteHeader(500)
+		log.Printf("Error uploading replay: %s", err)
+		w.WriteHeader(400)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	if _, err := io.Copy(f, file); err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
-		return
+	var part *multipart.Part
+	for {
+		part, err = reader.NextPart()
+		if err == io.EOF {
+			log.Printf("Error uploading replay: no replay field in form")
+			w.WriteHeader(400)
+			w.Write([]byte("Error uploading replay: no replay field in form"))
+			return
+		} else if err != nil {
+			log.Printf("Error uploading replay: %s", err)
+			w.WriteHeader(400)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		if part.FormName() == "replay" {
+			break
+		}
+		part.Close()
 	}
-	f.Close()
+	defer part.Close()
 
-	log.Printf("Uploaded file [%s] to: /tmp/%s", handler.Filename, handler.Filename)
-	replay, err := secretshop.NewReplay("/tmp/" + handler.Filename)
-	if err != nil {
+	job, err := h.queue.Submit(part.FileName(), part, modules)
+	if err == secretshop.ErrQueueFull {
+		log.Printf("Could not queue replay [%s]: %s", part.FileName(), err)
+		w.WriteHeader(503)
+		w.Write([]byte(fmt.Sprintf("Could not queue replay [%s]: %s", part.FileName(), err)))
+		return
+	} else if err == secretshop.ErrDuplicateReplay {
+		log.Printf("Could not queue replay [%s]: %s", part.FileName(), err)
+		w.WriteHeader(409)
+		w.Write([]byte(fmt.Sprintf("Could not queue replay [%s]: %s", part.FileName(), err)))
+		return
+	} else if err == secretshop.ErrNotReplay {
+		log.Printf("Could not queue replay [%s]: %s", part.FileName(), err)
+		w.WriteHeader(400)
+		w.Write([]byte(fmt.Sprintf("Could not queue replay [%s]: %s", part.FileName(), err)))
+		return
+	} else if err != nil {
+		log.Printf("Could not queue replay [%s]: %s", part.FileName(), err)
 		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		w.Write([]byte(fmt.Sprintf("Could not queue replay [%s]: %s", part.FileName(), err)))
 		return
 	}
 
-	log.Printf("Parsing Replay [%s]...", handler.Filename)
-	if err := replay.Parse(); err != nil {
-		log.Printf("Error parsing replay [%s]: %s", handler.Filename, err)
+	payload, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("Error marshalling job [%s] to json: %s", job.ID, err)
 		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		w.Write([]byte(fmt.Sprintf("Error marshalling job [%s] to json: %s", job.ID, err)))
 		return
 	}
-	replay.Process()
-
-	log.Printf("Finished parsing Replay [%s], saving item purchases...", handler.Filename)
-
-	for host, store := range h.conf.Stores {
-		info, err := store.LoadReplayInfo([]uint64{replay.GameID})
-		if err != nil {
-			log.Printf("Error loading replay [%d] from store [%s]: %s", replay.GameID, host, err)
-			w.WriteHeader(500)
-			w.Write([]byte(fmt.Sprintf("Error loading replay [%d] from store [%s]: %s", replay.GameID, host, err)))
-			return
-		}
-
-		if len(info) >= 1 {
-			log.Printf("Could not save replay info or item purchases, replay [%d] has already been parsed by a store [%s]", replay.GameID, host)
-			w.WriteHeader(409)
-			w.Write([]byte(fmt.Sprintf("Could not save replay info or item purchases, replay [%d] has already been parsed by a store [%s]", replay.GameID, host)))
-			return
-		}
-	}
 
-	count := 0
-	for _, purchase := range replay.ItemPurchases {
-		for host, store := range h.conf.Stores {
-			if err := store.SaveItemPurchase(purchase); err != nil {
-				log.Printf("Could not save purchase [%+v] to store [%s]. %s", purchase, host, err)
-			}
-		}
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Location", "/replay/jobs/"+job.ID)
+	w.WriteHeader(202)
+	w.Write(payload)
+}
 
-		count++
-	}
+func (h *Handler) replayJobGet(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
 
-	log.Printf("Finished saving itme purchases, saving player info...")
-	for _, player := range replay.PlayerInfo {
-		for host, store := range h.conf.Stores {
-			if err := store.SavePlayerInfo(player); err != nil {
-				log.Printf("Could not save player info [%+v] to store [%s]. %s", player, host, err)
-			}
-		}
+	job, ok := h.queue.Job(id)
+	if !ok {
+		log.Printf("Can't get job [%s], job does not exist", id)
+		w.WriteHeader(404)
+		w.Write([]byte(fmt.Sprintf("Can't get job [%s], job does not exist", id)))
+		return
 	}
 
-	log.Printf("Finished saving player info, saving replay info...")
-	log.Printf("%+v", replay.GameID)
-	for host, store := range h.conf.Stores {
-		if err := store.SaveReplayInfo(replay); err != nil {
-			log.Printf("Could not save replay info [%d] to store [%s]. %s", replay.GameID, host, err)
-		}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("Error marshalling job [%s] to json: %s", id, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Error marshalling job [%s] to json: %s", id, err)))
+		return
 	}
 
-	log.Printf("Succesfully parsed and saved Replay [%s]. Read %d Purchases and saved %d records", handler.Filename, len(replay.ItemPurchases), count)
-	w.WriteHeader(201)
-	w.Write([]byte(fmt.Sprintf("Succesfully parsed and saved Replay [%s]. Read %d Purchases and saved %d records", handler.Filename, len(replay.ItemPurchases), count)))
-	return
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(payload)
 }
 
 func (h *Handler) replayInfoGet(w http.ResponseWriter, r *http.Request) {
-	host := r.URL.Query().Get("host")
-	gameIdsRaw := r.URL.Query().Get("gameId")
-	log.Printf("Grabbing replay [%s] info from store [%s]", gameIdsRaw, host)
-
-	gameIDsData := strings.Split(gameIdsRaw, ",")
-	gameIDs := make([]uint64, len(gameIDsData))
-
-	if _, ok := h.conf.Stores[host]; !ok {
-		log.Printf("Can't get replay info from store [%s], store does not exist", host)
-		w.WriteHeader(404)
-		w.Write([]byte(fmt.Sprintf("Can't get replay info from store [%s], store does not exist", host)))
+	store, host, ok := h.store(w, r)
+	if !ok {
 		return
 	}
 
-	for i, id := range gameIDsData {
-		parsed, err := strconv.ParseUint(id, 10, 64)
-		if err != nil {
-			log.Printf("Could not get replay [%s] info: %s", id, err)
-			w.WriteHeader(400)
-			w.Write([]byte(fmt.Sprintf("Could not get replay [%s] info: %s", id, err)))
-			return
-		}
-		gameIDs[i] = parsed
-	}
-
-	replay, err := h.conf.Stores[host].LoadReplayInfo(gameIDs)
+	filters, err := parseFilters(r, "replayInfoGet", []string{"gameId", "gameMode", "winner", "leagueId", "team"}, nil)
 	if err != nil {
-		log.Printf("Error loading replay info [%s] from store [%s]: %s", gameIdsRaw, host, err)
-		w.WriteHeader(500)
-		w.Write([]byte(fmt.Sprintf("Error loading replay info [%s] from store [%s]: %s", gameIdsRaw, host, err)))
+		log.Printf("Error parsing filters in replayInfoGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
 		return
 	}
+	log.Printf("Grabbing replay info from store [%s] using filters [%+v]", host, filters)
 
-	data, err := json.Marshal(replay)
+	replay, err := store.LoadReplayInfo(filters)
 	if err != nil {
-		log.Printf("Error marshalling replay [%+v] to json: %s", replay, err)
+		log.Printf("Error loading replay info [%+v] from store [%s]: %s", filters, host, err)
 		w.WriteHeader(500)
-		w.Write([]byte(fmt.Sprintf("Error marshalling replay [%+v] to json: %s", replay, err)))
+		w.Write([]byte(fmt.Sprintf("Error loading replay info [%+v] from store [%s]: %s", filters, host, err)))
 		return
 	}
 
-	w.WriteHeader(200)
-	w.Header().Add("Content-Type", "application/json")
-	w.Write(data)
+	writeJSON(w, replay)
 }
 
 func (h *Handler) replayFriendlyNamePost(w http.ResponseWriter, r *http.Request) {
@@ -238,6 +249,32 @@ func (h *Handler) playerInfoGet(w http.ResponseWriter, r *http.Request) {
 	w.Write(payload)
 }
 
+func (h *Handler) playerSlotGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "playerSlotGet", []string{"gameId", "player", "team"}, []string{"hero", "side"})
+	if err != nil {
+		log.Printf("Error parsing filters in playerSlotGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Player Slots from store [%s] using filters [%+v]", host, filters)
+	p, err := store.LoadPlayerSlot(filters)
+	if err != nil {
+		log.Printf("Can't grab player slots from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab player slots from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, p)
+}
+
 func (h *Handler) itemPurchaseGet(w http.ResponseWriter, r *http.Request) {
 	host := r.URL.Query().Get("host")
 	log.Printf("Grabbing replay item purchases info from store [%s]", host)
@@ -249,51 +286,12 @@ func (h *Handler) itemPurchaseGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filters := make(map[string]interface{})
-	if filter := r.URL.Query().Get("gameId"); filter != "" {
-		log.Printf("Found filter [gameId] in itemPurchaseGet request, parsing...")
-		games := strings.Split(filter, ",")
-		data := make([]uint64, len(games))
-		for i, gameID := range games {
-			s, err := strconv.ParseUint(gameID, 10, 64)
-			if err != nil {
-				log.Printf("Error parsing filter [gameId] in itemPurchaseGet request: %s", err)
-				w.WriteHeader(500)
-				w.Write([]byte(fmt.Sprintf("Error reading filter [gameId] in itemPurchaseGet request: %s", err)))
-				return
-			}
-			data[i] = s
-		}
-		filters["gameId"] = data
-	}
-
-	if filter := r.URL.Query().Get("player"); filter != "" {
-		log.Printf("Found filter [player] in itemPurchaseGet request, parsing...")
-		players := strings.Split(filter, ",")
-		data := make([]uint64, len(players))
-		for i, steamID := range players {
-			s, err := strconv.ParseUint(steamID, 10, 64)
-			if err != nil {
-				log.Printf("Error parsing filter [player] in itemPurchaseGet request: %s", err)
-				w.WriteHeader(500)
-				w.Write([]byte(fmt.Sprintf("Error reading filter [player] in itemPurchaseGet request: %s", err)))
-				return
-			}
-			data[i] = s
-		}
-		filters["player"] = data
-	}
-
-	if filter := r.URL.Query().Get("hero"); filter != "" {
-		log.Printf("Found filter [hero] in itemPurchaseGet request, parsing...")
-		heroes := strings.Split(filter, ",")
-		filters["hero"] = heroes
-	}
-
-	if filter := r.URL.Query().Get("item"); filter != "" {
-		log.Printf("Found filter [item] in itemPurchaseGet request, parsing...")
-		items := strings.Split(filter, ",")
-		filters["item"] = items
+	filters, err := parseFilters(r, "itemPurchaseGet", []string{"gameId", "player"}, []string{"hero", "item"})
+	if err != nil {
+		log.Printf("Error parsing filters in itemPurchaseGet request: %s", err)
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+		return
 	}
 
 	log.Printf("Loading Item Purchases from store [%s] using filters [%+v]", host, filters)
@@ -316,6 +314,644 @@ func (h *Handler) itemPurchaseGet(w http.ResponseWriter, r *http.Request) {
 	w.Write(payload)
 }
 
+func (h *Handler) itemLifecycleGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "itemLifecycleGet", []string{"gameId", "player"}, []string{"hero", "item", "removedBy"})
+	if err != nil {
+		log.Printf("Error parsing filters in itemLifecycleGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Item Lifecycles from store [%s] using filters [%+v]", host, filters)
+	i, err := store.LoadItemLifecycle(filters)
+	if err != nil {
+		log.Printf("Can't grab item lifecycles from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab item lifecycles from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, i)
+}
+
+func (h *Handler) itemAssemblyGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "itemAssemblyGet", []string{"gameId", "player"}, []string{"hero", "item"})
+	if err != nil {
+		log.Printf("Error parsing filters in itemAssemblyGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Item Assemblies from store [%s] using filters [%+v]", host, filters)
+	a, err := store.LoadItemAssembly(filters)
+	if err != nil {
+		log.Printf("Can't grab item assemblies from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab item assemblies from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, a)
+}
+
+// itemTiming is how long after the horn a player finished their first of an item
+type itemTiming struct {
+	GameID  uint64  `json:"gameId"`
+	SteamID uint64  `json:"steamId"`
+	Hero    string  `json:"hero"`
+	Item    string  `json:"item"`
+	Time    float32 `json:"time"`
+}
+
+// itemTimings contains the first timings for an item along with a summary
+type itemTimings struct {
+	Timings []itemTiming `json:"timings"`
+	Average float32      `json:"average"`
+	Fastest float32      `json:"fastest"`
+}
+
+func (h *Handler) itemTimingGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "itemTimingGet", []string{"gameId", "player"}, []string{"hero", "item"})
+	if err != nil {
+		log.Printf("Error parsing filters in itemTimingGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if _, ok := filters["item"]; !ok {
+		log.Printf("Could not get item timings: item cannot be null")
+		w.WriteHeader(400)
+		w.Write([]byte("Could not get item timings: item cannot be null"))
+		return
+	}
+
+	for _, item := range filters["item"].([]string) {
+		if !secretshop.IsAssembledItem(item) {
+			log.Printf("Could not get item timings: item [%s] is not built from a recipe", item)
+			w.WriteHeader(400)
+			w.Write([]byte(fmt.Sprintf("Could not get item timings: item [%s] is not built from a recipe", item)))
+			return
+		}
+	}
+
+	log.Printf("Loading Item Timings from store [%s] using filters [%+v]", host, filters)
+	assemblies, err := store.LoadItemAssembly(filters)
+	if err != nil {
+		log.Printf("Can't grab item assemblies from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab item assemblies from store [%s]: %s", host, err)))
+		return
+	}
+
+	timings := itemTimings{Timings: []itemTiming{}}
+	first := make(map[string]bool)
+	var total float32
+	for _, a := range assemblies {
+		key := fmt.Sprintf("%d:%d:%s", a.GameID, a.SteamID, a.Item)
+		if first[key] {
+			continue
+		}
+		first[key] = true
+
+		t := itemTiming{
+			GameID:  a.GameID,
+			SteamID: a.SteamID,
+			Hero:    a.Hero,
+			Item:    a.Item,
+			Time:    a.GameTime,
+		}
+		timings.Timings = append(timings.Timings, t)
+
+		total += t.Time
+		if len(timings.Timings) == 1 || t.Time < timings.Fastest {
+			timings.Fastest = t.Time
+		}
+	}
+
+	if len(timings.Timings) > 0 {
+		timings.Average = total / float32(len(timings.Timings))
+	}
+
+	writeJSON(w, timings)
+}
+
+func (h *Handler) skillBuildGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "skillBuildGet", []string{"gameId", "player"}, []string{"hero", "ability"})
+	if err != nil {
+		log.Printf("Error parsing filters in skillBuildGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Skill Builds from store [%s] using filters [%+v]", host, filters)
+	b, err := store.LoadSkillBuild(filters)
+	if err != nil {
+		log.Printf("Can't grab skill builds from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab skill builds from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, b)
+}
+
+func (h *Handler) killEventGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "killEventGet", []string{"gameId", "player"}, []string{"hero", "killer", "victim"})
+	if err != nil {
+		log.Printf("Error parsing filters in killEventGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Kill Events from store [%s] using filters [%+v]", host, filters)
+	k, err := store.LoadKillEvent(filters)
+	if err != nil {
+		log.Printf("Can't grab kill events from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab kill events from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, k)
+}
+
+func (h *Handler) timelineGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "timelineGet", []string{"gameId", "player"}, []string{"hero"})
+	if err != nil {
+		log.Printf("Error parsing filters in timelineGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Timeline from store [%s] using filters [%+v]", host, filters)
+	t, err := store.LoadTimeline(filters)
+	if err != nil {
+		log.Printf("Can't grab timeline from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab timeline from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, t)
+}
+
+func (h *Handler) wardGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "wardGet", []string{"gameId", "player", "team"}, []string{"hero", "type"})
+	if err != nil {
+		log.Printf("Error parsing filters in wardGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Wards from store [%s] using filters [%+v]", host, filters)
+	wards, err := store.LoadWard(filters)
+	if err != nil {
+		log.Printf("Can't grab wards from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab wards from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, wards)
+}
+
+func (h *Handler) objectiveEventGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "objectiveEventGet", []string{"gameId", "player", "team"}, []string{"hero", "type"})
+	if err != nil {
+		log.Printf("Error parsing filters in objectiveEventGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Objective Events from store [%s] using filters [%+v]", host, filters)
+	objectives, err := store.LoadObjectiveEvent(filters)
+	if err != nil {
+		log.Printf("Can't grab objective events from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab objective events from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, objectives)
+}
+
+func (h *Handler) runeEventGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "runeEventGet", []string{"gameId", "player"}, []string{"hero", "rune", "event"})
+	if err != nil {
+		log.Printf("Error parsing filters in runeEventGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Rune Events from store [%s] using filters [%+v]", host, filters)
+	runes, err := store.LoadRuneEvent(filters)
+	if err != nil {
+		log.Printf("Can't grab rune events from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab rune events from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, runes)
+}
+
+func (h *Handler) buybackEventGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "buybackEventGet", []string{"gameId", "player"}, []string{"hero"})
+	if err != nil {
+		log.Printf("Error parsing filters in buybackEventGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Buybacks from store [%s] using filters [%+v]", host, filters)
+	buybacks, err := store.LoadBuybackEvent(filters)
+	if err != nil {
+		log.Printf("Can't grab buybacks from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab buybacks from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, buybacks)
+}
+
+func (h *Handler) goldSpendingGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "goldSpendingGet", []string{"gameId", "player"}, []string{"hero"})
+	if err != nil {
+		log.Printf("Error parsing filters in goldSpendingGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Gold Spending from store [%s] using filters [%+v]", host, filters)
+	spending, err := store.LoadGoldSpending(filters)
+	if err != nil {
+		log.Printf("Can't grab gold spending from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab gold spending from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, spending)
+}
+
+// damageTotal is the total amount and hits across a set of damage aggregates
+type damageTotal struct {
+	Amount uint64 `json:"amount"`
+	Hits   uint32 `json:"hits"`
+}
+
+// damageTotals contains damage aggregates along with totals for each kind, so
+// a single inflictor can be summed across matches. Kinds are totalled apart as
+// damage between heroes is stored as both dealt and taken, and healing isn't
+// damage at all
+type damageTotals struct {
+	Aggregates []secretshop.DamageAggregate `json:"aggregates"`
+	Totals     map[string]damageTotal       `json:"totals"`
+}
+
+func (h *Handler) damageGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "damageGet", []string{"gameId", "player"}, []string{"hero", "kind", "inflictor", "target"})
+	if err != nil {
+		log.Printf("Error parsing filters in damageGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Damage from store [%s] using filters [%+v]", host, filters)
+	aggregates, err := store.LoadDamageAggregate(filters)
+	if err != nil {
+		log.Printf("Can't grab damage from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab damage from store [%s]: %s", host, err)))
+		return
+	}
+
+	totals := damageTotals{
+		Aggregates: []secretshop.DamageAggregate{},
+		Totals:     make(map[string]damageTotal),
+	}
+	for _, d := range aggregates {
+		totals.Aggregates = append(totals.Aggregates, d)
+
+		total := totals.Totals[d.Kind]
+		total.Amount += d.Amount
+		total.Hits += d.Hits
+		totals.Totals[d.Kind] = total
+	}
+
+	writeJSON(w, totals)
+}
+
+func (h *Handler) teamfightGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "teamfightGet", []string{"gameId", "number"}, []string{})
+	if err != nil {
+		log.Printf("Error parsing filters in teamfightGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Teamfights from store [%s] using filters [%+v]", host, filters)
+	fights, err := store.LoadTeamfight(filters)
+	if err != nil {
+		log.Printf("Can't grab teamfights from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab teamfights from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, fights)
+}
+
+func (h *Handler) chatMessageGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "chatMessageGet", []string{"gameId", "player"}, []string{"hero", "channel"})
+	if err != nil {
+		log.Printf("Error parsing filters in chatMessageGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Searches are matched as a whole rather than split on commas
+	if search := r.URL.Query().Get("search"); search != "" {
+		filters["search"] = search
+	}
+
+	log.Printf("Loading Chat Messages from store [%s] using filters [%+v]", host, filters)
+	messages, err := store.LoadChatMessage(filters)
+	if err != nil {
+		log.Printf("Can't grab chat messages from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab chat messages from store [%s]: %s", host, err)))
+		return
+	}
+
+	writeJSON(w, messages)
+}
+
+// itemDeliveries contains item deliveries along with the average time it took
+// each item to reach an inventory after being bought
+type itemDeliveries struct {
+	Deliveries []secretshop.ItemDelivery `json:"deliveries"`
+	Latency    map[string]float32        `json:"latency"`
+}
+
+func (h *Handler) itemDeliveryGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "itemDeliveryGet", []string{"gameId", "player"}, []string{"hero", "item"})
+	if err != nil {
+		log.Printf("Error parsing filters in itemDeliveryGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("Loading Item Deliveries from store [%s] using filters [%+v]", host, filters)
+	deliveries, err := store.LoadItemDelivery(filters)
+	if err != nil {
+		log.Printf("Can't grab item deliveries from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab item deliveries from store [%s]: %s", host, err)))
+		return
+	}
+
+	result := itemDeliveries{
+		Deliveries: []secretshop.ItemDelivery{},
+		Latency:    make(map[string]float32),
+	}
+	counts := make(map[string]int)
+	for _, d := range deliveries {
+		result.Deliveries = append(result.Deliveries, d)
+		if d.Delivered == 0 {
+			continue
+		}
+
+		result.Latency[d.Item] += d.Latency
+		counts[d.Item]++
+	}
+
+	for item, count := range counts {
+		result.Latency[item] /= float32(count)
+	}
+
+	writeJSON(w, result)
+}
+
+// inventoryGet returns inventory snapshots, if a time is given only the latest
+// snapshot of each hero at or before that game time is returned
+func (h *Handler) inventoryGet(w http.ResponseWriter, r *http.Request) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := parseFilters(r, "inventoryGet", []string{"gameId", "player"}, []string{"hero", "reason"})
+	if err != nil {
+		log.Printf("Error parsing filters in inventoryGet request: %s", err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	at := r.URL.Query().Get("time")
+	if at != "" {
+		t, err := strconv.ParseFloat(at, 32)
+		if err != nil {
+			log.Printf("Error parsing time in inventoryGet request: %s", err)
+			w.WriteHeader(400)
+			w.Write([]byte(fmt.Sprintf("Error parsing time in inventoryGet request: %s", err)))
+			return
+		}
+		filters["to"] = float32(t)
+	}
+
+	log.Printf("Loading Inventory Snapshots from store [%s] using filters [%+v]", host, filters)
+	snapshots, err := store.LoadInventorySnapshot(filters)
+	if err != nil {
+		log.Printf("Can't grab inventory snapshots from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab inventory snapshots from store [%s]: %s", host, err)))
+		return
+	}
+
+	if at == "" {
+		writeJSON(w, snapshots)
+		return
+	}
+
+	// Snapshots are ordered by time, so the last one seen for a hero is the
+	// latest before the requested time
+	latest := []secretshop.InventorySnapshot{}
+	index := make(map[string]int)
+	for _, s := range snapshots {
+		key := fmt.Sprintf("%d:%s", s.GameID, s.Hero)
+		if i, ok := index[key]; ok {
+			latest[i] = s
+			continue
+		}
+
+		index[key] = len(latest)
+		latest = append(latest, s)
+	}
+
+	writeJSON(w, latest)
+}
+
+// defaultHeatmapCell is the size of a heatmap square in world units if the
+// request doesn't ask for one
+const defaultHeatmapCell = 512
+
+func (h *Handler) positionGet(w http.ResponseWriter, r *http.Request) {
+	paths, filters, ok := h.loadPaths(w, r, "positionGet")
+	if !ok {
+		return
+	}
+
+	from, to := window(filters)
+	for i := range paths {
+		paths[i].Points = paths[i].Window(from, to)
+	}
+
+	writeJSON(w, paths)
+}
+
+func (h *Handler) heatmapGet(w http.ResponseWriter, r *http.Request) {
+	cell := int64(defaultHeatmapCell)
+	if value := r.URL.Query().Get("cell"); value != "" {
+		var err error
+		cell, err = strconv.ParseInt(value, 10, 32)
+		if err != nil || cell <= 0 {
+			log.Printf("Could not build heatmap: invalid cell size [%s]", value)
+			w.WriteHeader(400)
+			w.Write([]byte(fmt.Sprintf("Could not build heatmap: invalid cell size [%s]", value)))
+			return
+		}
+	}
+
+	paths, filters, ok := h.loadPaths(w, r, "heatmapGet")
+	if !ok {
+		return
+	}
+
+	from, to := window(filters)
+	writeJSON(w, secretshop.Heatmap(paths, from, to, int32(cell)))
+}
+
+// loadPaths loads the hero paths matching a request's filters, writing an error
+// response if they can't be loaded
+func (h *Handler) loadPaths(w http.ResponseWriter, r *http.Request, name string) ([]secretshop.HeroPath, map[string]interface{}, bool) {
+	store, host, ok := h.store(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	filters, err := parseFilters(r, name, []string{"gameId", "player"}, []string{"hero"})
+	if err != nil {
+		log.Printf("Error parsing filters in %s request: %s", name, err)
+		w.WriteHeader(400)
+		w.Write([]byte(err.Error()))
+		return nil, nil, false
+	}
+
+	log.Printf("Loading Hero Paths from store [%s] using filters [%+v]", host, filters)
+	paths, err := store.LoadHeroPath(filters)
+	if err != nil {
+		log.Printf("Can't grab hero paths from store [%s]: %s", host, err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Can't grab hero paths from store [%s]: %s", host, err)))
+		return nil, nil, false
+	}
+
+	return paths, filters, true
+}
+
 func (h *Handler) isAuthenticated(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if h.conf.Auth == "" {
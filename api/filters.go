@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/oliread/secretshop"
+)
+
+// parseFilters reads comma separated filters from a request's query string,
+// uints are parsed as lists of ids and strs are passed through as lists of names.
+// The from and to parameters are always read as a window of game clock time
+func parseFilters(r *http.Request, name string, uints []string, strs []string) (map[string]interface{}, error) {
+	filters := make(map[string]interface{})
+
+	for _, key := range uints {
+		filter := r.URL.Query().Get(key)
+		if filter == "" {
+			continue
+		}
+
+		log.Printf("Found filter [%s] in %s request, parsing...", key, name)
+		values := strings.Split(filter, ",")
+		data := make([]uint64, len(values))
+		for i, value := range values {
+			s, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Error reading filter [%s] in %s request: %s", key, name, err)
+			}
+			data[i] = s
+		}
+		filters[key] = data
+	}
+
+	for _, key := range strs {
+		if filter := r.URL.Query().Get(key); filter != "" {
+			log.Printf("Found filter [%s] in %s request, parsing...", key, name)
+			filters[key] = strings.Split(filter, ",")
+		}
+	}
+
+	for _, key := range []string{"from", "to"} {
+		filter := r.URL.Query().Get(key)
+		if filter == "" {
+			continue
+		}
+
+		log.Printf("Found filter [%s] in %s request, parsing...", key, name)
+		f, err := strconv.ParseFloat(filter, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading filter [%s] in %s request: %s", key, name, err)
+		}
+		filters[key] = float32(f)
+	}
+
+	return filters, nil
+}
+
+// window returns the from and to filters, or the widest possible window if
+// they weren't set
+func window(filters map[string]interface{}) (from float32, to float32) {
+	from, to = -math.MaxFloat32, math.MaxFloat32
+	if f, ok := filters["from"].(float32); ok {
+		from = f
+	}
+	if t, ok := filters["to"].(float32); ok {
+		to = t
+	}
+
+	return from, to
+}
+
+// store looks up the store named by the host query parameter, writing a 404 if
+// it doesn't exist
+func (h *Handler) store(w http.ResponseWriter, r *http.Request) (secretshop.Store, string, bool) {
+	host := r.URL.Query().Get("host")
+	store, ok := h.conf.Stores[host]
+	if !ok {
+		log.Printf("Can't get data from store [%s], store does not exist", host)
+		w.WriteHeader(404)
+		w.Write([]byte(fmt.Sprintf("Can't get data from store [%s], store does not exist", host)))
+		return nil, host, false
+	}
+
+	return store, host, true
+}
+
+// writeJSON marshals a payload and writes it as the response
+func writeJSON(w http.ResponseWriter, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling payload as JSON: %s", err)
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Error marshalling payload as JSON: %s", err)))
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(200)
+	w.Write(data)
+}
@@ -0,0 +1,123 @@
+package secretshop
+
+import (
+	"sort"
+)
+
+// ItemAssembly contains information about a hero completing an item from its
+// components, or buying it outright in which case Components is empty
+type ItemAssembly struct {
+	GameID     uint64   `json:"gameId"`
+	SteamID    uint64   `json:"steamId"`
+	Hero       string   `json:"hero"`
+	Item       string   `json:"item"`
+	Timestamp  float32  `json:"timestamp"`
+	GameTime   float32  `json:"gameTime"`
+	Components []string `json:"components"`
+}
+
+// processAssemblies walks through each hero's purchases in order, keeping
+// track of the components they hold, and records an assembly whenever they
+// hold everything needed for a recipe. Finished items are kept as components
+// so that items built from other finished items are found too. Components that
+// were sold or used up are dropped from what the hero holds, and finished items
+// bought outright are recorded as assemblies with no components
+func (r *Replay) processAssemblies() {
+	purchases := make(map[string][]*ItemPurchase)
+	heroes := []string{}
+	for _, p := range r.ItemPurchases {
+		if _, ok := purchases[p.Hero]; !ok {
+			heroes = append(heroes, p.Hero)
+		}
+		purchases[p.Hero] = append(purchases[p.Hero], p)
+	}
+
+	removals := make(map[string][]*ItemLifecycle)
+	for _, item := range r.ItemLifecycles {
+		if item.RemovedBy == ItemSold || item.RemovedBy == ItemConsumed {
+			removals[item.Hero] = append(removals[item.Hero], item)
+		}
+	}
+
+	for _, hero := range heroes {
+		bought := purchases[hero]
+		sort.SliceStable(bought, func(i, j int) bool {
+			return bought[i].Timestamp < bought[j].Timestamp
+		})
+
+		removed := removals[hero]
+		sort.SliceStable(removed, func(i, j int) bool {
+			return removed[i].Removed < removed[j].Removed
+		})
+
+		held := make(map[string]int)
+		for _, p := range bought {
+			for len(removed) > 0 && removed[0].Removed < p.Timestamp {
+				if held[removed[0].Item] > 0 {
+					held[removed[0].Item]--
+				}
+				removed = removed[1:]
+			}
+
+			held[p.Item]++
+			if _, ok := recipes[p.Item]; ok {
+				r.ItemAssemblies = append(r.ItemAssemblies, &ItemAssembly{
+					GameID:     r.GameID,
+					SteamID:    r.Players[hero],
+					Hero:       hero,
+					Item:       p.Item,
+					Timestamp:  p.Timestamp,
+					Components: []string{},
+				})
+			}
+
+			for {
+				item, components := nextAssembly(held)
+				if item == "" {
+					break
+				}
+
+				for _, component := range components {
+					held[component]--
+				}
+				held[item]++
+
+				r.ItemAssemblies = append(r.ItemAssemblies, &ItemAssembly{
+					GameID:     r.GameID,
+					SteamID:    r.Players[hero],
+					Hero:       hero,
+					Item:       item,
+					Timestamp:  p.Timestamp,
+					Components: components,
+				})
+			}
+		}
+	}
+}
+
+// nextAssembly returns the first item, and the components used, that can be
+// built from the items held
+func nextAssembly(held map[string]int) (string, []string) {
+	for _, item := range recipeOrder {
+		for _, components := range recipes[item] {
+			needed := make(map[string]int)
+			for _, component := range components {
+				needed[component]++
+			}
+
+			complete := true
+			for component, count := range needed {
+				if held[component] < count {
+					complete = false
+					break
+				}
+			}
+
+			if complete {
+				return item, components
+			}
+		}
+	}
+
+	return "", nil
+}
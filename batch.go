@@ -0,0 +1,201 @@
+package secretshop
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Source is a replay to be parsed by ParseAll. Replays are only opened once a
+// worker is free to parse them, so a long list of sources costs very little
+type Source struct {
+	Name string
+	Open func() (*Replay, error)
+}
+
+// FileSource returns a source that parses a replay file
+func FileSource(fileName string) Source {
+	return Source{
+		Name: fileName,
+		Open: func() (*Replay, error) {
+			return NewReplay(fileName)
+		},
+	}
+}
+
+// ParseOptions controls how ParseAll parses replays
+type ParseOptions struct {
+	// Workers is the number of replays parsed at once, it defaults to the
+	// number of CPUs
+	Workers int
+
+	// Configure is called on every replay before it is parsed, to set things
+	// like the sample interval and parser modules
+	Configure func(*Replay)
+
+	// Timeout limits how long a single replay can take to parse, there is no
+	// limit if it's 0
+	Timeout time.Duration
+}
+
+// ParseResult is a replay parsed by ParseAll, or the error that stopped it
+// being parsed. Bytes is how much of the replay was read and Stats is the
+// throughput of the whole batch up to and including this replay
+type ParseResult struct {
+	Source   Source
+	Replay   *Replay
+	Err      error
+	Bytes    int64
+	Duration time.Duration
+	Stats    ParseStats
+}
+
+// ParseStats totals up the replays parsed by ParseAll
+type ParseStats struct {
+	Parsed  int           `json:"parsed"`
+	Failed  int           `json:"failed"`
+	Bytes   int64         `json:"bytes"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// ReplaysPerSecond returns how many replays, parsed or failed, have been
+// finished each second
+func (s ParseStats) ReplaysPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(s.Parsed+s.Failed) / s.Elapsed.Seconds()
+}
+
+// BytesPerSecond returns how many bytes of replay have been read each second
+func (s ParseStats) BytesPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+
+	return float64(s.Bytes) / s.Elapsed.Seconds()
+}
+
+// ParseAll parses and processes replays using a pool of workers, sending each
+// one back as soon as it is finished, so results are in the order replays
+// finish rather than the order they were given. Workers wait for each result
+// to be read before starting another replay, which keeps at most one parsed
+// replay per worker in memory. The results channel is closed once every source
+// is finished, or once the context is cancelled
+func ParseAll(ctx context.Context, sources []Source, opts ParseOptions) <-chan ParseResult {
+	pending := make(chan Source)
+	go func() {
+		defer close(pending)
+		for _, source := range sources {
+			select {
+			case pending <- source:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ParseStream(ctx, pending, opts)
+}
+
+// ParseStream works the same way as ParseAll for sources that arrive over
+// time, such as uploaded replays. The results channel is closed once sources
+// is closed and every replay read from it is finished, or once the context is
+// cancelled
+func ParseStream(ctx context.Context, sources <-chan Source, opts ParseOptions) <-chan ParseResult {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make(chan ParseResult)
+
+	var (
+		mu    sync.Mutex
+		stats ParseStats
+		start = time.Now()
+		wg    sync.WaitGroup
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var source Source
+				select {
+				case next, ok := <-sources:
+					if !ok {
+						return
+					}
+					source = next
+				case <-ctx.Done():
+					return
+				}
+
+				result := parseSource(ctx, source, opts)
+
+				mu.Lock()
+				if result.Err != nil {
+					stats.Failed++
+				} else {
+					stats.Parsed++
+				}
+				stats.Bytes += result.Bytes
+				stats.Elapsed = time.Since(start)
+				result.Stats = stats
+				mu.Unlock()
+
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// parseSource opens, parses and processes a single replay
+func parseSource(ctx context.Context, source Source, opts ParseOptions) (result ParseResult) {
+	result.Source = source
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	replay, err := source.Open()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if opts.Configure != nil {
+		opts.Configure(replay)
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	result.Err = replay.ParseContext(ctx)
+	result.Bytes = replay.read
+	if result.Err != nil {
+		return result
+	}
+	replay.Process()
+	result.Replay = replay
+
+	return result
+}
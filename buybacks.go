@@ -0,0 +1,142 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// BuybackEvent contains information about a player buying back, along with
+// whether the gold they had left covered the next item they bought
+type BuybackEvent struct {
+	GameID              uint64  `json:"gameId"`
+	Hero                string  `json:"hero"`
+	SteamID             uint64  `json:"steamId"`
+	Timestamp           float32 `json:"timestamp"`
+	GameTime            float32 `json:"gameTime"`
+	Cost                uint32  `json:"cost"`
+	GoldAfter           int32   `json:"goldAfter"`
+	NextItem            string  `json:"nextItem,omitempty"`
+	NextItemCost        uint32  `json:"nextItemCost,omitempty"`
+	CouldAffordNextItem bool    `json:"couldAffordNextItem"`
+
+	playerID int32
+	tick     uint32
+}
+
+// GoldSpending contains a breakdown of where a player's gold went in a match
+type GoldSpending struct {
+	GameID      uint64 `json:"gameId"`
+	SteamID     uint64 `json:"steamId"`
+	Hero        string `json:"hero"`
+	Items       uint32 `json:"items"`
+	Consumables uint32 `json:"consumables"`
+	Buybacks    uint32 `json:"buybacks"`
+	Deaths      uint32 `json:"deaths"`
+	Total       uint32 `json:"total"`
+}
+
+// parseBuyback records a buyback from the combat log, the player's gold is
+// read from the entities on the next tick once it has been taken
+func (r *Replay) parseBuyback(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	b := &BuybackEvent{
+		Timestamp: m.GetTimestamp(),
+		playerID:  int32(m.GetValue()),
+		tick:      p.Tick,
+	}
+
+	r.Buybacks = append(r.Buybacks, b)
+	r.pendingBuybacks = append(r.pendingBuybacks, b)
+}
+
+// parseBuybackGold reads the gold each player who bought back was left with
+func (r *Replay) parseBuybackGold(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	if e.GetClassName() != "CDOTAGamerulesProxy" || len(r.pendingBuybacks) == 0 {
+		return nil
+	}
+
+	pr := findEntity(p, "CDOTA_PlayerResource")
+	radiant := findEntity(p, "CDOTA_DataRadiant")
+	dire := findEntity(p, "CDOTA_DataDire")
+	if pr == nil || radiant == nil || dire == nil {
+		return nil
+	}
+
+	pending := r.pendingBuybacks[:0]
+	for _, b := range r.pendingBuybacks {
+		if p.Tick <= b.tick {
+			pending = append(pending, b)
+			continue
+		}
+
+		if prop, ok := teamData(pr, radiant, dire, int(b.playerID)); ok {
+			b.GoldAfter = prop("m_iReliableGold") + prop("m_iUnreliableGold")
+		}
+	}
+	r.pendingBuybacks = pending
+
+	return nil
+}
+
+// processBuybacks fills in the hero and cost of each buyback and finds the
+// next item they bought afterwards
+func (r *Replay) processBuybacks() {
+	for _, b := range r.Buybacks {
+		b.GameID = r.GameID
+		b.Hero = r.heroByPlayerID(b.playerID)
+		b.SteamID = r.Players[b.Hero]
+		b.Cost = r.goldSpent(b.Hero, goldReasonBuyback, b.Timestamp)
+
+		for _, purchase := range r.ItemPurchases {
+			if purchase.Hero != b.Hero || purchase.Timestamp < b.Timestamp {
+				continue
+			}
+
+			b.NextItem = purchase.Item
+			b.NextItemCost = r.goldSpent(b.Hero, goldReasonPurchaseItem, purchase.Timestamp) +
+				r.goldSpent(b.Hero, goldReasonPurchaseConsumable, purchase.Timestamp)
+			b.CouldAffordNextItem = b.GoldAfter >= int32(b.NextItemCost)
+			break
+		}
+	}
+}
+
+// processGoldSpending totals up the gold each player spent on items and
+// buybacks and lost to dying
+func (r *Replay) processGoldSpending() {
+	spending := make(map[string]*GoldSpending)
+	for _, slot := range r.PlayerSlots {
+		s := &GoldSpending{
+			GameID:  r.GameID,
+			SteamID: slot.SteamID,
+			Hero:    slot.Hero,
+		}
+		spending[slot.Hero] = s
+		r.GoldSpending = append(r.GoldSpending, s)
+	}
+
+	for _, change := range r.goldChanges {
+		s, ok := spending[change.Hero]
+		if !ok {
+			continue
+		}
+
+		amount := uint32(change.Amount)
+		if change.Amount < 0 {
+			amount = uint32(-change.Amount)
+		}
+
+		switch change.Reason {
+		case goldReasonPurchaseItem:
+			s.Items += amount
+		case goldReasonPurchaseConsumable:
+			s.Consumables += amount
+		case goldReasonBuyback:
+			s.Buybacks += amount
+		case goldReasonDeath:
+			s.Deaths += amount
+		default:
+			continue
+		}
+		s.Total += amount
+	}
+}
@@ -0,0 +1,135 @@
+package secretshop
+
+import (
+	"strings"
+
+	"github.com/dotabuff/manta/dota"
+)
+
+// Chat channels a message can be sent in
+const (
+	ChatAll       = "all"
+	ChatTeam      = "team"
+	ChatSpectator = "spectator"
+	ChatWheel     = "wheel"
+)
+
+// chatChannels maps the DOTAChatChannelType_t of chat messages to channels
+var chatChannels = map[uint32]string{
+	11: ChatAll,
+	12: ChatTeam,
+	13: ChatSpectator,
+}
+
+// ChatMessage contains a message sent by a player during a match. Chat wheel
+// messages have no text, just the id of the chat wheel line used
+type ChatMessage struct {
+	GameID    uint64  `json:"gameId"`
+	SteamID   uint64  `json:"steamId"`
+	Sender    string  `json:"sender"`
+	Hero      string  `json:"hero,omitempty"`
+	Channel   string  `json:"channel"`
+	Message   string  `json:"message,omitempty"`
+	WheelID   uint32  `json:"wheelId,omitempty"`
+	Timestamp float32 `json:"timestamp"`
+	GameTime  float32 `json:"gameTime"`
+
+	playerID int32
+}
+
+// parseChatMessage records a chat message sent by a player id
+func (r *Replay) parseChatMessage(m *dota.CDOTAUserMsg_ChatMessage) {
+	channel, ok := chatChannels[m.GetChannelType()]
+	if !ok {
+		return
+	}
+
+	r.addChat(&ChatMessage{
+		Channel:   channel,
+		Message:   m.GetMessageText(),
+		Timestamp: r.gameTime,
+		playerID:  m.GetSourcePlayerId(),
+	})
+}
+
+// parseSayText records a chat message from the older say text messages, which
+// give the sender's name rather than their player id
+func (r *Replay) parseSayText(m *dota.CUserMessageSayText2) {
+	if !strings.HasPrefix(m.GetMessagename(), "DOTA_Chat_") {
+		return
+	}
+
+	channel := ChatAll
+	if strings.Contains(m.GetMessagename(), "Team") {
+		channel = ChatTeam
+	}
+
+	r.addChat(&ChatMessage{
+		Sender:    m.GetParam1(),
+		Channel:   channel,
+		Message:   m.GetParam2(),
+		Timestamp: r.gameTime,
+		playerID:  -1,
+	})
+}
+
+// parseChatWheel records a chat wheel line being used
+func (r *Replay) parseChatWheel(m *dota.CDOTAUserMsg_ChatWheel) {
+	r.addChat(&ChatMessage{
+		Channel:   ChatWheel,
+		WheelID:   m.GetChatMessageId(),
+		Timestamp: r.gameTime,
+		playerID:  int32(m.GetPlayerId()),
+	})
+}
+
+// addChat adds a message unless it's already been recorded, as newer replays
+// can send the same message as both a chat message and say text
+func (r *Replay) addChat(message *ChatMessage) {
+	for i := len(r.Chat) - 1; i >= 0; i-- {
+		other := r.Chat[i]
+		if other.Timestamp != message.Timestamp {
+			break
+		}
+
+		if other.Channel == message.Channel && other.Message == message.Message && other.WheelID == message.WheelID {
+			return
+		}
+	}
+
+	r.Chat = append(r.Chat, message)
+}
+
+// processChat fills in the sender of each message, matching player ids to
+// heroes and player names to steam ids
+func (r *Replay) processChat() {
+	names := make(map[string]uint64)
+	for _, player := range r.PlayerInfo {
+		names[player.Name] = player.SteamID
+	}
+
+	heroes := make(map[uint64]string)
+	for hero, steamID := range r.Players {
+		heroes[steamID] = hero
+	}
+
+	for _, message := range r.Chat {
+		message.GameID = r.GameID
+		if message.playerID >= 0 {
+			message.Hero = r.heroByPlayerID(message.playerID)
+			message.SteamID = r.Players[message.Hero]
+		} else {
+			message.SteamID = names[message.Sender]
+			message.Hero = heroes[message.SteamID]
+		}
+
+		if message.Sender != "" {
+			continue
+		}
+		for _, player := range r.PlayerInfo {
+			if player.SteamID == message.SteamID {
+				message.Sender = player.Name
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta"
+)
+
+// Pause contains the raw timestamps a game was paused and unpaused at, End is
+// 0 if the game was still paused when the replay finished
+type Pause struct {
+	Start    float32 `json:"start"`
+	End      float32 `json:"end"`
+	GameTime float32 `json:"gameTime"`
+}
+
+// parsePause records the game being paused and unpaused from the game rules
+func (r *Replay) parsePause(e *manta.Entity, op manta.EntityOp) error {
+	if e.GetClassName() != "CDOTAGamerulesProxy" {
+		return nil
+	}
+
+	paused, ok := e.GetBool("m_pGameRules.m_bGamePaused")
+	if !ok || paused == r.paused {
+		return nil
+	}
+	r.paused = paused
+
+	if paused {
+		r.Pauses = append(r.Pauses, &Pause{Start: r.gameTime})
+	} else if n := len(r.Pauses); n > 0 {
+		r.Pauses[n-1].End = r.gameTime
+	}
+
+	return nil
+}
+
+// GameClock converts a raw timestamp, as used by the combat log, into the time
+// shown on the in game clock: seconds since the horn, negative before it, and
+// not counting any time the game spent paused
+func (r *Replay) GameClock(timestamp float32) float32 {
+	if timestamp >= r.GameStart {
+		return timestamp - r.GameStart - r.pausedBetween(r.GameStart, timestamp)
+	}
+
+	return timestamp - r.GameStart + r.pausedBetween(timestamp, r.GameStart)
+}
+
+// pausedBetween returns how long the game was paused for between two raw
+// timestamps
+func (r *Replay) pausedBetween(from, to float32) float32 {
+	var paused float32
+	for _, pause := range r.Pauses {
+		start, end := pause.Start, pause.End
+		if end == 0 || end > to {
+			end = to
+		}
+		if start < from {
+			start = from
+		}
+
+		if end > start {
+			paused += end - start
+		}
+	}
+
+	return paused
+}
+
+// processGameTime fills in the game clock time of every event
+func (r *Replay) processGameTime() {
+	for _, pause := range r.Pauses {
+		pause.GameTime = r.GameClock(pause.Start)
+	}
+	for _, p := range r.ItemPurchases {
+		p.GameTime = r.GameClock(p.Timestamp)
+	}
+	for _, k := range r.Kills {
+		k.GameTime = r.GameClock(k.Timestamp)
+	}
+	for _, item := range r.ItemLifecycles {
+		item.GameTime = r.GameClock(item.Acquired)
+	}
+	for _, a := range r.ItemAssemblies {
+		a.GameTime = r.GameClock(a.Timestamp)
+	}
+	for _, s := range r.Timeline {
+		s.GameTime = r.GameClock(s.Timestamp)
+	}
+	for _, build := range r.SkillBuilds {
+		for _, pick := range build.Skills {
+			pick.GameTime = r.GameClock(pick.Timestamp)
+		}
+	}
+	for _, ward := range r.Wards {
+		ward.GameTime = r.GameClock(ward.Placed)
+	}
+	for _, path := range r.Paths {
+		for i := range path.Points {
+			path.Points[i].GameTime = r.GameClock(path.Points[i].Timestamp)
+		}
+	}
+	for _, o := range r.Objectives {
+		o.GameTime = r.GameClock(o.Timestamp)
+	}
+	for _, event := range r.Runes {
+		event.GameTime = r.GameClock(event.Timestamp)
+	}
+	for _, b := range r.Buybacks {
+		b.GameTime = r.GameClock(b.Timestamp)
+	}
+	for _, fight := range r.Fights {
+		fight.GameTime = r.GameClock(fight.Start)
+	}
+	for _, message := range r.Chat {
+		message.GameTime = r.GameClock(message.Timestamp)
+	}
+	for _, delivery := range r.Deliveries {
+		delivery.GameTime = r.GameClock(delivery.Purchased)
+	}
+	for _, s := range r.Inventories {
+		s.GameTime = r.GameClock(s.Timestamp)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"time"
 
@@ -25,6 +28,10 @@ func main() {
 		log.Print("WARNING: you are running Secret Shop without an authentication key set, be careful using this in the wild")
 	}
 
+	if conf.MaxReplayMB > 0 {
+		secretshop.MaxReplaySize = conf.MaxReplayMB * 1024 * 1024
+	}
+
 	for host, data := range conf.StoreInfo {
 		switch host {
 		case "mysql":
@@ -42,7 +49,23 @@ func main() {
 		}
 	}
 
-	apiHandler, err := api.NewHandler(conf)
+	queue := secretshop.NewQueue(conf)
+	if err := queue.Start(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Started replay queue with %d workers", conf.Workers)
+
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+
+		log.Print("Stopping replay queue...")
+		queue.Stop()
+		os.Exit(0)
+	}()
+
+	apiHandler, err := api.NewHandler(conf, queue)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/oliread/secretshop"
+	"github.com/oliread/secretshop/store/mysql"
+)
+
+// replayExtensions are the file endings of replays picked up from directories
+var replayExtensions = []string{".dem", ".dem.bz2", ".dem.gz", ".dem.zst"}
+
+func main() {
+	confFile := flag.String("conf", "", "Location of config file, used for parser settings and stores")
+	workers := flag.Int("workers", 0, "Number of replays to parse at once, defaults to the number of CPUs")
+	modules := flag.String("modules", "", "Comma separated list of parser modules to run, defaults to all of them")
+	outDir := flag.String("out", "", "Directory to write each parsed replay to as json")
+	save := flag.Bool("save", false, "Save parsed replays to the stores in the config")
+	timeout := flag.Duration("timeout", 0, "Longest a single replay can take to parse")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] replay|directory...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conf := secretshop.Config{Stores: make(map[string]secretshop.Store)}
+	if *confFile != "" {
+		var err error
+		conf, err = secretshop.ReadConfig(*confFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if conf.MaxReplayMB > 0 {
+		secretshop.MaxReplaySize = conf.MaxReplayMB * 1024 * 1024
+	}
+
+	if *modules != "" {
+		conf.Modules = strings.Split(*modules, ",")
+		if err := secretshop.CheckModules(conf.Modules); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *save {
+		if len(conf.StoreInfo) == 0 {
+			log.Fatal("Can't save replays, no stores in the config")
+		}
+
+		for host, data := range conf.StoreInfo {
+			switch host {
+			case "mysql":
+				if err := mysql.NewStore(&conf, data); err != nil {
+					log.Fatalf("Error connecting to database [%s]: %s", host, err)
+				}
+			}
+		}
+	}
+
+	sources, err := findReplays(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Parsing %d replays...", len(sources))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+
+		log.Print("Stopping parser...")
+		cancel()
+	}()
+
+	var stats secretshop.ParseStats
+	results := secretshop.ParseAll(ctx, sources, secretshop.ParseOptions{
+		Workers:   *workers,
+		Configure: conf.Configure,
+		Timeout:   *timeout,
+	})
+	for result := range results {
+		stats = result.Stats
+		if result.Err != nil {
+			log.Printf("Error parsing replay [%s]: %s", result.Source.Name, result.Err)
+			continue
+		}
+
+		replay := result.Replay
+		log.Printf("Parsed replay [%s] for game [%d] in %s", result.Source.Name, replay.GameID, result.Duration.Round(time.Millisecond))
+
+		if *outDir != "" {
+			if err := writeReplay(*outDir, replay); err != nil {
+				log.Printf("Error writing replay [%s]: %s", result.Source.Name, err)
+			}
+		}
+
+		if *save {
+			if err := secretshop.SaveReplay(conf.Stores, replay); err != nil {
+				log.Printf("Error saving replay [%s]: %s", result.Source.Name, err)
+			}
+		}
+	}
+
+	log.Printf("Parsed %d replays, %d failed, in %s (%.2f replays/s, %.2f MB/s)",
+		stats.Parsed, stats.Failed, stats.Elapsed.Round(time.Millisecond),
+		stats.ReplaysPerSecond(), stats.BytesPerSecond()/1024/1024)
+
+	if stats.Failed > 0 || ctx.Err() != nil {
+		os.Exit(1)
+	}
+}
+
+// findReplays turns a list of replays and directories into sources, walking
+// directories for anything that looks like a replay
+func findReplays(paths []string) ([]secretshop.Source, error) {
+	sources := []secretshop.Source{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			sources = append(sources, secretshop.FileSource(path))
+			continue
+		}
+
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() && isReplay(file) {
+				sources = append(sources, secretshop.FileSource(file))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sources, nil
+}
+
+func isReplay(file string) bool {
+	for _, ext := range replayExtensions {
+		if strings.HasSuffix(file, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeReplay writes a replay to a directory as json, named after its game id
+func writeReplay(dir string, replay *secretshop.Replay) error {
+	data, err := json.Marshal(replay)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.json", replay.GameID)), data, 0644)
+}
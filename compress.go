@@ -0,0 +1,92 @@
+package secretshop
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// MaxReplaySize is the largest a replay is allowed to be once decompressed,
+// replays which grow past it stop parsing with ErrReplayTooLarge
+var MaxReplaySize int64 = 1024 * 1024 * 1024
+
+// ErrReplayTooLarge is returned when a replay decompresses to more than MaxReplaySize
+var ErrReplayTooLarge = errors.New("replay is larger than the maximum replay size")
+
+var (
+	bzip2Magic = []byte("BZh")
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompress sniffs the first few bytes of a replay and, if it has been
+// compressed with bzip2, gzip or zstd, decompresses it on the fly. Plain demo
+// files are passed through untouched
+func decompress(src io.Reader) (*limitedReader, error) {
+	br := bufio.NewReader(src)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("unable to read replay: %s", err)
+	}
+
+	var r io.ReadCloser
+	switch {
+	case bytes.HasPrefix(magic, bzip2Magic):
+		r = ioutil.NopCloser(bzip2.NewReader(br))
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read gzip replay: %s", err)
+		}
+		r = gz
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read zstd replay: %s", err)
+		}
+		r = zr.IOReadCloser()
+	default:
+		r = ioutil.NopCloser(br)
+	}
+
+	return &limitedReader{ReadCloser: r, n: MaxReplaySize}, nil
+}
+
+// compressed returns true if the first few bytes of a replay show it has been
+// compressed with bzip2, gzip or zstd
+func compressed(magic []byte) bool {
+	return bytes.HasPrefix(magic, bzip2Magic) || bytes.HasPrefix(magic, gzipMagic) || bytes.HasPrefix(magic, zstdMagic)
+}
+
+// limitedReader reads up to n bytes before failing with ErrReplayTooLarge
+type limitedReader struct {
+	io.ReadCloser
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(b []byte) (int, error) {
+	if l.n <= 0 {
+		// Only complain if there really is more data to come
+		if n, err := l.ReadCloser.Read(make([]byte, 1)); n == 0 {
+			return 0, err
+		}
+		l.exceeded = true
+		return 0, ErrReplayTooLarge
+	}
+
+	if int64(len(b)) > l.n {
+		b = b[:l.n]
+	}
+
+	n, err := l.ReadCloser.Read(b)
+	l.n -= int64(n)
+	return n, err
+}
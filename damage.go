@@ -0,0 +1,94 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// Kinds of damage aggregate
+const (
+	DamageDealt = "dealt"
+	DamageTaken = "taken"
+	Healing     = "healing"
+)
+
+// damageAttack is the inflictor given to damage and healing from right clicks
+// and other sources without an ability or item
+const damageAttack = "attack"
+
+// DamageAggregate contains the total damage or healing a hero did, or took,
+// with one inflictor against one other unit. Target is the unit damaged or
+// healed for dealt and healing aggregates, and the attacker for taken ones
+type DamageAggregate struct {
+	GameID    uint64 `json:"gameId"`
+	SteamID   uint64 `json:"steamId"`
+	Hero      string `json:"hero"`
+	Kind      string `json:"kind"`
+	Inflictor string `json:"inflictor"`
+	Target    string `json:"target"`
+	Amount    uint64 `json:"amount"`
+	Hits      uint32 `json:"hits"`
+}
+
+// damageKey identifies a single damage aggregate while parsing
+type damageKey struct {
+	hero      string
+	kind      string
+	inflictor string
+	target    string
+}
+
+// parseDamage adds a damage or heal entry from the combat log to the running
+// totals for the heroes involved, illusions are ignored
+func (r *Replay) parseDamage(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	attacker, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetAttackerName()))
+	target, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetTargetName()))
+	inflictor, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetInflictorName()))
+	if inflictor == "" || inflictor == "dota_unknown" {
+		inflictor = damageAttack
+	}
+
+	attackerIsHero := m.GetIsAttackerHero() && !m.GetIsAttackerIllusion()
+	targetIsHero := m.GetIsTargetHero() && !m.GetIsTargetIllusion()
+
+	if m.GetType() == dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_HEAL {
+		if attackerIsHero {
+			r.addDamage(damageKey{attacker, Healing, inflictor, target}, m.GetValue())
+		}
+		return
+	}
+
+	if attackerIsHero {
+		r.addDamage(damageKey{attacker, DamageDealt, inflictor, target}, m.GetValue())
+	}
+
+	if targetIsHero {
+		r.addDamage(damageKey{target, DamageTaken, inflictor, attacker}, m.GetValue())
+	}
+}
+
+// addDamage adds an amount to the aggregate for a key, creating it if needed
+func (r *Replay) addDamage(key damageKey, amount uint32) {
+	d, ok := r.damage[key]
+	if !ok {
+		d = &DamageAggregate{
+			Hero:      key.hero,
+			Kind:      key.kind,
+			Inflictor: key.inflictor,
+			Target:    key.target,
+		}
+		r.damage[key] = d
+		r.Damage = append(r.Damage, d)
+	}
+
+	d.Amount += uint64(amount)
+	d.Hits++
+}
+
+// processDamage fills in the game and steam id for each aggregate
+func (r *Replay) processDamage() {
+	for _, d := range r.Damage {
+		d.GameID = r.GameID
+		d.SteamID = r.Players[d.Hero]
+	}
+}
@@ -0,0 +1,116 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta"
+)
+
+// Places an item can be while it's on its way to a hero
+const (
+	locationStash     = "stash"
+	locationCourier   = "courier"
+	locationInventory = "inventory"
+)
+
+// ItemDelivery contains information about how a purchased item reached a
+// hero's inventory. Items bought in range of a shop go straight to the
+// inventory, otherwise they're stashed until a courier picks them up or the
+// hero goes back to base. Latency is the time from purchase to inventory
+type ItemDelivery struct {
+	GameID    uint64  `json:"gameId"`
+	SteamID   uint64  `json:"steamId"`
+	Hero      string  `json:"hero"`
+	Item      string  `json:"item"`
+	Purchased float32 `json:"purchased"`
+	GameTime  float32 `json:"gameTime"`
+	Stashed   bool    `json:"stashed"`
+	PickedUp  float32 `json:"pickedUp,omitempty"`
+	Delivered float32 `json:"delivered,omitempty"`
+	Latency   float32 `json:"latency,omitempty"`
+	ByCourier bool    `json:"byCourier"`
+
+	location string
+}
+
+// parseDeliveries follows items through hero stashes, couriers and inventories
+// by watching which slots hold them
+func (r *Replay) parseDeliveries(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	courier := e.GetClassName() == "CDOTA_Unit_Courier"
+	if (!courier && !isHero(e)) || op.Flag(manta.EntityOpDeleted) {
+		return nil
+	}
+
+	for slot, item := range entityItems(p, e) {
+		if item == nil {
+			continue
+		}
+
+		location := locationInventory
+		if courier {
+			location = locationCourier
+		} else if slot >= stashStart && slot <= stashEnd {
+			location = locationStash
+		}
+
+		r.moveItem(item.GetIndex(), location)
+	}
+
+	return nil
+}
+
+// moveItem records an item being seen somewhere, starting a delivery the first
+// time it's seen and finishing it once it reaches an inventory. Deliveries
+// follow the item's lifecycle rather than its entity index, as indexes are
+// reused once items are gone
+func (r *Replay) moveItem(index int32, location string) {
+	lifecycle, ok := r.liveItems[index]
+	if !ok {
+		return
+	}
+
+	delivery, ok := r.deliveries[lifecycle]
+	if !ok {
+		delivery = &ItemDelivery{
+			Hero:      lifecycle.Hero,
+			Item:      lifecycle.Item,
+			Purchased: lifecycle.Acquired,
+		}
+		r.deliveries[lifecycle] = delivery
+		r.Deliveries = append(r.Deliveries, delivery)
+	}
+
+	if delivery.Delivered != 0 || delivery.location == location {
+		return
+	}
+	delivery.location = location
+
+	switch location {
+	case locationStash:
+		delivery.Stashed = true
+	case locationCourier:
+		if delivery.PickedUp == 0 {
+			delivery.PickedUp = r.gameTime
+		}
+		delivery.ByCourier = true
+	case locationInventory:
+		delivery.Delivered = r.gameTime
+		delivery.Latency = delivery.Delivered - delivery.Purchased
+	}
+}
+
+// processDeliveries keeps only the deliveries of items that were bought, as
+// items made from recipes or given at the start of the game aren't delivered
+func (r *Replay) processDeliveries() {
+	deliveries := []*ItemDelivery{}
+	for _, delivery := range r.Deliveries {
+		for _, purchase := range r.ItemPurchases {
+			if purchase.Hero == delivery.Hero && purchase.Item == delivery.Item && abs32(purchase.Timestamp-delivery.Purchased) <= sameMoment {
+				delivery.GameID = r.GameID
+				delivery.SteamID = r.Players[delivery.Hero]
+				deliveries = append(deliveries, delivery)
+				break
+			}
+		}
+	}
+
+	r.Deliveries = deliveries
+}
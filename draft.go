@@ -0,0 +1,52 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta/dota"
+)
+
+// Draft contains information about the picks and bans of a match along with
+// the teams that played it and the result
+type Draft struct {
+	GameMode       int32            `json:"gameMode"`
+	Winner         int32            `json:"winner"`
+	LeagueID       uint32           `json:"leagueId"`
+	RadiantTeamID  uint32           `json:"radiantTeamId"`
+	DireTeamID     uint32           `json:"direTeamId"`
+	RadiantTeamTag string           `json:"radiantTeamTag"`
+	DireTeamTag    string           `json:"direTeamTag"`
+	EndTime        uint32           `json:"endTime"`
+	PicksBans      []DraftSelection `json:"picksBans"`
+}
+
+// DraftSelection contains information about a single pick or ban
+type DraftSelection struct {
+	Order  int    `json:"order"`
+	IsPick bool   `json:"isPick"`
+	Team   uint32 `json:"team"`
+	HeroID uint32 `json:"heroId"`
+}
+
+// parseDraft reads the draft from the game info at the end of a replay, picks
+// and bans are stored in the order they were made
+func (r *Replay) parseDraft(data *dota.CGameInfo_CDotaGameInfo) {
+	r.Draft = &Draft{
+		GameMode:       data.GetGameMode(),
+		Winner:         data.GetGameWinner(),
+		LeagueID:       data.GetLeagueid(),
+		RadiantTeamID:  data.GetRadiantTeamId(),
+		DireTeamID:     data.GetDireTeamId(),
+		RadiantTeamTag: data.GetRadiantTeamTag(),
+		DireTeamTag:    data.GetDireTeamTag(),
+		EndTime:        data.GetEndTime(),
+		PicksBans:      []DraftSelection{},
+	}
+
+	for i, selection := range data.GetPicksBans() {
+		r.Draft.PicksBans = append(r.Draft.PicksBans, DraftSelection{
+			Order:  i,
+			IsPick: selection.GetIsPick(),
+			Team:   selection.GetTeam(),
+			HeroID: selection.GetHeroId(),
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package secretshop
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dotabuff/manta"
+)
+
+// maxPlayers is the number of player slots in the player resource entity
+const maxPlayers = 24
+
+// Team numbers used by entities
+const (
+	teamRadiant = 2
+	teamDire    = 3
+)
+
+// Inventory slots of a unit. Heroes have their stash in slots 9 to 14 and
+// their teleport scroll and neutral item after it
+const (
+	itemSlots  = 17
+	stashStart = 9
+	stashEnd   = 14
+)
+
+// cellWidth is the size of a cell in the entity position grid, and mapOffset
+// moves cell coordinates back to world coordinates centred on the middle of
+// the map
+const (
+	cellWidth = 128
+	mapOffset = 16384
+)
+
+// parseClock keeps track of the in game clock from the game rules entity, so
+// that anything read from entities can be timestamped the same way as the
+// combat log
+func (r *Replay) parseClock(e *manta.Entity, op manta.EntityOp) error {
+	if e.GetClassName() != "CDOTAGamerulesProxy" {
+		return nil
+	}
+
+	if t, ok := e.GetFloat32("m_pGameRules.m_fGameTime"); ok {
+		r.gameTime = t
+	}
+
+	return nil
+}
+
+// entityName returns the internal name of an entity, such as npc_dota_hero_axe
+// or item_blink
+func entityName(p *manta.Parser, e *manta.Entity) string {
+	index, ok := e.GetInt32("m_pEntity.m_nameStringableIndex")
+	if !ok {
+		return ""
+	}
+
+	name, _ := p.LookupStringByIndex("EntityNames", index)
+	return name
+}
+
+// entityOwner returns the entity that owns another, such as the hero carrying
+// an item or placing a ward
+func entityOwner(p *manta.Parser, e *manta.Entity) *manta.Entity {
+	handle, ok := e.GetUint32("m_hOwnerEntity")
+	if !ok {
+		return nil
+	}
+
+	return p.FindEntityByHandle(uint64(handle))
+}
+
+// isHero returns true for entities which are heroes, rather than units
+func isHero(e *manta.Entity) bool {
+	return e != nil && strings.HasPrefix(e.GetClassName(), "CDOTA_Unit_Hero_")
+}
+
+// findEntity returns the first entity with a class name
+func findEntity(p *manta.Parser, class string) *manta.Entity {
+	entities := p.FilterEntity(func(e *manta.Entity) bool {
+		return e.GetClassName() == class
+	})
+
+	if len(entities) == 0 {
+		return nil
+	}
+
+	return entities[0]
+}
+
+// entityPosition returns an entity's position in world coordinates
+func entityPosition(e *manta.Entity) (x float32, y float32, ok bool) {
+	cellX, okCellX := e.GetUint64("CBodyComponent.m_cellX")
+	cellY, okCellY := e.GetUint64("CBodyComponent.m_cellY")
+	vecX, okVecX := e.GetFloat32("CBodyComponent.m_vecX")
+	vecY, okVecY := e.GetFloat32("CBodyComponent.m_vecY")
+	if !okCellX || !okCellY || !okVecX || !okVecY {
+		return 0, 0, false
+	}
+
+	x = float32(cellX)*cellWidth + vecX - mapOffset
+	y = float32(cellY)*cellWidth + vecY - mapOffset
+	return x, y, true
+}
+
+// entityItems returns the items a unit is carrying indexed by inventory slot,
+// empty slots are nil
+func entityItems(p *manta.Parser, e *manta.Entity) []*manta.Entity {
+	items := make([]*manta.Entity, itemSlots)
+	for slot := 0; slot < itemSlots; slot++ {
+		handle, ok := e.GetUint32(fmt.Sprintf("m_hItems.%04d", slot))
+		if !ok {
+			continue
+		}
+		items[slot] = p.FindEntityByHandle(uint64(handle))
+	}
+
+	return items
+}
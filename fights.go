@@ -0,0 +1,177 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// fightGap is the longest gap, in seconds, between deaths or hero damage for
+// them to still be part of the same fight, and fightMinDeaths the number of
+// deaths needed for a cluster to count as a teamfight
+const (
+	fightGap       = 15
+	fightMinDeaths = 3
+)
+
+// Teamfight contains information about a cluster of hero deaths and the hero
+// damage around them. GoldSwing is the gold lost by Dire heroes dying in the
+// fight less the gold lost by Radiant heroes, so it's positive if the fight
+// went in Radiant's favour
+type Teamfight struct {
+	GameID        uint64   `json:"gameId"`
+	Number        int      `json:"number"`
+	Start         float32  `json:"start"`
+	GameTime      float32  `json:"gameTime"`
+	End           float32  `json:"end"`
+	Participants  []string `json:"participants"`
+	Deaths        []string `json:"deaths"`
+	RadiantDeaths int      `json:"radiantDeaths"`
+	DireDeaths    int      `json:"direDeaths"`
+	GoldSwing     int32    `json:"goldSwing"`
+}
+
+// heroDamage is a hero damaging an enemy hero, kept so fights can be extended
+// to cover the damage leading up to and following the deaths in them
+type heroDamage struct {
+	Attacker  string
+	Target    string
+	Timestamp float32
+}
+
+// parseHeroDamage records damage between heroes from the combat log
+func (r *Replay) parseHeroDamage(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	if !m.GetIsAttackerHero() || m.GetIsAttackerIllusion() || !m.GetIsTargetHero() || m.GetIsTargetIllusion() {
+		return
+	}
+
+	attacker, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetAttackerName()))
+	target, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetTargetName()))
+	if attacker == target {
+		return
+	}
+
+	// Damage after a quiet spell could be the start of a fight, so take a
+	// snapshot of everyone's items in case it turns out to be one
+	n := len(r.heroDamage)
+	if n == 0 || m.GetTimestamp()-r.heroDamage[n-1].Timestamp > fightGap {
+		r.requestSnapshot("", InventoryFight, m.GetTimestamp())
+	}
+
+	r.heroDamage = append(r.heroDamage, heroDamage{
+		Attacker:  attacker,
+		Target:    target,
+		Timestamp: m.GetTimestamp(),
+	})
+}
+
+// processFights clusters kills into teamfights, stretches each one to cover
+// the hero damage either side of it and then annotates every item purchase
+// with the fight closest to it. Kills must be processed first
+func (r *Replay) processFights() {
+	sides := make(map[string]int32)
+	for _, slot := range r.PlayerSlots {
+		sides[slot.Hero] = slot.gameTeam
+	}
+
+	clusters := [][]*KillEvent{}
+	for _, k := range r.Kills {
+		n := len(clusters)
+		if n > 0 {
+			last := clusters[n-1][len(clusters[n-1])-1]
+			if k.Timestamp-last.Timestamp <= fightGap {
+				clusters[n-1] = append(clusters[n-1], k)
+				continue
+			}
+		}
+		clusters = append(clusters, []*KillEvent{k})
+	}
+
+	for _, kills := range clusters {
+		if len(kills) < fightMinDeaths {
+			continue
+		}
+
+		fight := &Teamfight{
+			GameID:       r.GameID,
+			Number:       len(r.Fights) + 1,
+			Start:        kills[0].Timestamp,
+			End:          kills[len(kills)-1].Timestamp,
+			Participants: []string{},
+			Deaths:       []string{},
+		}
+
+		participants := make(map[string]bool)
+		addParticipant := func(hero string) {
+			if _, ok := sides[hero]; ok && !participants[hero] {
+				participants[hero] = true
+				fight.Participants = append(fight.Participants, hero)
+			}
+		}
+
+		for _, k := range kills {
+			fight.Deaths = append(fight.Deaths, k.Victim)
+			addParticipant(k.Victim)
+			addParticipant(k.Killer)
+			for _, assister := range k.Assisters {
+				addParticipant(assister)
+			}
+
+			switch sides[k.Victim] {
+			case teamRadiant:
+				fight.RadiantDeaths++
+				fight.GoldSwing -= int32(k.GoldLost)
+			case teamDire:
+				fight.DireDeaths++
+				fight.GoldSwing += int32(k.GoldLost)
+			}
+		}
+
+		// Damage is in time order, so walk backwards from the first death and
+		// forwards from the last to find where the fighting started and ended
+		for i := len(r.heroDamage) - 1; i >= 0; i-- {
+			d := r.heroDamage[i]
+			if d.Timestamp >= fight.Start {
+				continue
+			}
+			if fight.Start-d.Timestamp > fightGap {
+				break
+			}
+			fight.Start = d.Timestamp
+		}
+
+		for _, d := range r.heroDamage {
+			if d.Timestamp < fight.Start {
+				continue
+			}
+			if d.Timestamp-fight.End > fightGap {
+				break
+			}
+
+			if d.Timestamp > fight.End {
+				fight.End = d.Timestamp
+			}
+			addParticipant(d.Attacker)
+			addParticipant(d.Target)
+		}
+
+		r.Fights = append(r.Fights, fight)
+	}
+
+	for _, purchase := range r.ItemPurchases {
+		var nearest float32
+		for _, fight := range r.Fights {
+			offset := float32(0)
+			if purchase.Timestamp < fight.Start {
+				offset = purchase.Timestamp - fight.Start
+			} else if purchase.Timestamp > fight.End {
+				offset = purchase.Timestamp - fight.End
+			}
+
+			if purchase.NearestFight == 0 || abs32(offset) < nearest {
+				purchase.NearestFight = fight.Number
+				purchase.FightOffset = offset
+				nearest = abs32(offset)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// parseGameState records when the game moved into strategy time, started and
+// ended from the combat log
+func (r *Replay) parseGameState(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	v := m.GetValue()
+	if v == uint32(dota.DOTA_GameState_DOTA_GAMERULES_STATE_GAME_IN_PROGRESS) {
+		r.GameStart = m.GetTimestamp()
+	} else if v == uint32(dota.DOTA_GameState_DOTA_GAMERULES_STATE_STRATEGY_TIME) {
+		r.StrategyStart = m.GetTimestamp()
+	} else if v == uint32(dota.DOTA_GameState_DOTA_GAMERULES_STATE_POST_GAME) {
+		r.GameEnd = m.GetTimestamp()
+	}
+}
+
+// parseFileInfo reads the match id, draft and players from the game info at
+// the end of a replay. The entities are still there at this point, so player
+// ids and teams are read from them too
+func (r *Replay) parseFileInfo(p *manta.Parser, m *dota.CDemoFileInfo) {
+	r.parseGameInfo(m)
+	r.parsePlayerResource(p)
+}
+
+// parseGameInfo reads the match id, draft and players from a replay's file info
+func (r *Replay) parseGameInfo(m *dota.CDemoFileInfo) {
+	data := m.GetGameInfo().GetDota()
+	r.GameID = data.GetMatchId()
+	r.parseDraft(data)
+	for _, player := range data.PlayerInfo {
+		playerInfo := PlayerInfo{
+			SteamID: player.GetSteamid(),
+			Name:    player.GetPlayerName(),
+		}
+		r.PlayerInfo = append(r.PlayerInfo, &playerInfo)
+		r.Players[player.GetHeroName()] = player.GetSteamid()
+		r.PlayerSlots = append(r.PlayerSlots, &PlayerSlot{
+			SteamID:  player.GetSteamid(),
+			Hero:     player.GetHeroName(),
+			gameTeam: player.GetGameTeam(),
+		})
+	}
+}
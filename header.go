@@ -0,0 +1,138 @@
+package secretshop
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dotabuff/manta/dota"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+// demoMagic is at the start of every Source 2 replay, followed by the offset of
+// the file info and the offset of the spawn groups
+var demoMagic = []byte("PBDEMS2\x00")
+
+// demoHeaderSize is the size of the magic and the two offsets after it
+const demoHeaderSize = 16
+
+// maxFileInfoSize is the largest file info message that will be read, it is
+// normally only a few kilobytes
+const maxFileInfoSize = 1024 * 1024
+
+var (
+	// ErrNotReplay is returned when a file doesn't start like a replay
+	ErrNotReplay = errors.New("file is not a dota 2 replay")
+
+	// ErrNoFileInfo is returned when a replay doesn't have any file info, which
+	// happens if the game didn't finish recording
+	ErrNoFileInfo = errors.New("replay has no file info")
+
+	// ErrCompressedReplay is returned when reading the header of a compressed
+	// replay, which can only be reached by decompressing the whole replay
+	ErrCompressedReplay = errors.New("replay is compressed")
+)
+
+// ReadHeader reads the match id, draft and players of a replay from the file
+// info at the end of it, without parsing anything else. Only uncompressed
+// replays can skip straight to the file info, compressed replays are refused
+// with ErrCompressedReplay
+func ReadHeader(fileName string) (*Replay, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %s", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, demoHeaderSize)
+	if _, err := f.ReadAt(header, 0); err != nil || !bytes.HasPrefix(header, demoMagic) {
+		if compressed(header) {
+			return nil, ErrCompressedReplay
+		}
+		return nil, ErrNotReplay
+	}
+
+	offset, err := fileInfoOffset(header)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("unable to read replay: %s", err)
+	}
+
+	info, err := readFileInfo(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Replay{Players: make(map[string]uint64)}
+	r.parseGameInfo(info)
+	return r, nil
+}
+
+// fileInfoOffset checks the header of a replay and returns where its file info is
+func fileInfoOffset(header []byte) (int64, error) {
+	if !bytes.HasPrefix(header, demoMagic) {
+		return 0, ErrNotReplay
+	}
+
+	offset := int64(binary.LittleEndian.Uint32(header[len(demoMagic):]))
+	if offset < demoHeaderSize {
+		return 0, ErrNoFileInfo
+	}
+
+	return offset, nil
+}
+
+// readFileInfo reads the file info message from the start of a reader. Each
+// message in a replay is its command, tick and size as varints followed by the
+// message itself, which may be compressed with snappy
+func readFileInfo(r *bufio.Reader) (*dota.CDemoFileInfo, error) {
+	cmd, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file info: %s", err)
+	}
+
+	if _, err := binary.ReadUvarint(r); err != nil {
+		return nil, fmt.Errorf("unable to read file info: %s", err)
+	}
+
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file info: %s", err)
+	}
+
+	compressed := cmd&uint64(dota.EDemoCommands_DEM_IsCompressed) != 0
+	cmd &^= uint64(dota.EDemoCommands_DEM_IsCompressed)
+	if cmd != uint64(dota.EDemoCommands_DEM_FileInfo) {
+		return nil, ErrNoFileInfo
+	}
+
+	if size > maxFileInfoSize {
+		return nil, fmt.Errorf("unable to read file info: message is %d bytes", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("unable to read file info: %s", err)
+	}
+
+	if compressed {
+		if data, err = snappy.Decode(nil, data); err != nil {
+			return nil, fmt.Errorf("unable to decompress file info: %s", err)
+		}
+	}
+
+	info := &dota.CDemoFileInfo{}
+	if err := proto.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("unable to decode file info: %s", err)
+	}
+
+	return info, nil
+}
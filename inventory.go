@@ -0,0 +1,162 @@
+package secretshop
+
+import (
+	"fmt"
+
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// Reasons an inventory snapshot was taken
+const (
+	InventoryInterval = "interval"
+	InventoryDeath    = "death"
+	InventoryFight    = "fight"
+)
+
+// Inventory slots after the stash
+const (
+	slotTeleport = 15
+	slotNeutral  = 16
+)
+
+// InventorySnapshot contains the items a hero was carrying at a point in the
+// game. Slots are kept in order, with empty slots as empty strings
+type InventorySnapshot struct {
+	GameID    uint64   `json:"gameId"`
+	SteamID   uint64   `json:"steamId"`
+	Hero      string   `json:"hero"`
+	Reason    string   `json:"reason"`
+	Timestamp float32  `json:"timestamp"`
+	GameTime  float32  `json:"gameTime"`
+	Inventory []string `json:"inventory"`
+	Backpack  []string `json:"backpack"`
+	Stash     []string `json:"stash"`
+	Teleport  string   `json:"teleport,omitempty"`
+	Neutral   string   `json:"neutral,omitempty"`
+
+	trigger float32
+}
+
+// snapshotRequest asks for heroes' inventories to be read on the next update
+// of the game rules, an empty hero means every hero
+type snapshotRequest struct {
+	Hero    string
+	Reason  string
+	Trigger float32
+}
+
+// requestSnapshot asks for an inventory snapshot once entities are next read
+func (r *Replay) requestSnapshot(hero string, reason string, trigger float32) {
+	if !r.snapshots {
+		return
+	}
+
+	r.snapshotRequests = append(r.snapshotRequests, snapshotRequest{
+		Hero:    hero,
+		Reason:  reason,
+		Trigger: trigger,
+	})
+}
+
+// parseInventoryDeath asks for the inventory of a hero that has just died
+func (r *Replay) parseInventoryDeath(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	if !m.GetIsTargetHero() || m.GetIsTargetIllusion() {
+		return
+	}
+
+	hero, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetTargetName()))
+	r.requestSnapshot(hero, InventoryDeath, m.GetTimestamp())
+}
+
+// parseInventory takes inventory snapshots every inventory interval and for
+// any snapshots requested since the last update
+func (r *Replay) parseInventory(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	if e.GetClassName() != "CDOTAGamerulesProxy" {
+		return nil
+	}
+
+	interval := r.InventoryInterval > 0 && r.gameTime >= r.nextInventory
+	if !interval && len(r.snapshotRequests) == 0 {
+		return nil
+	}
+
+	pr := findEntity(p, "CDOTA_PlayerResource")
+	if pr == nil {
+		return nil
+	}
+
+	heroes := []*manta.Entity{}
+	for i := 0; i < maxPlayers; i++ {
+		handle, ok := pr.GetUint32(fmt.Sprintf("m_vecPlayerTeamData.%04d.m_hSelectedHero", i))
+		if !ok {
+			continue
+		}
+
+		if hero := p.FindEntityByHandle(uint64(handle)); isHero(hero) {
+			heroes = append(heroes, hero)
+		}
+	}
+
+	if interval {
+		r.nextInventory = r.gameTime + r.InventoryInterval
+		for _, hero := range heroes {
+			r.snapshotInventory(p, hero, InventoryInterval, r.gameTime)
+		}
+	}
+
+	for _, request := range r.snapshotRequests {
+		for _, hero := range heroes {
+			if request.Hero == "" || request.Hero == entityName(p, hero) {
+				r.snapshotInventory(p, hero, request.Reason, request.Trigger)
+			}
+		}
+	}
+	r.snapshotRequests = r.snapshotRequests[:0]
+
+	return nil
+}
+
+// snapshotInventory reads the items in each of a hero's slots
+func (r *Replay) snapshotInventory(p *manta.Parser, hero *manta.Entity, reason string, trigger float32) {
+	names := make([]string, itemSlots)
+	for slot, item := range entityItems(p, hero) {
+		if item != nil {
+			names[slot] = entityName(p, item)
+		}
+	}
+
+	r.Inventories = append(r.Inventories, &InventorySnapshot{
+		Hero:      entityName(p, hero),
+		Reason:    reason,
+		Timestamp: r.gameTime,
+		Inventory: names[:6],
+		Backpack:  names[6:stashStart],
+		Stash:     names[stashStart : stashEnd+1],
+		Teleport:  names[slotTeleport],
+		Neutral:   names[slotNeutral],
+		trigger:   trigger,
+	})
+}
+
+// processInventories drops snapshots taken when heroes started fighting that
+// didn't turn into a teamfight. Fights must be processed first
+func (r *Replay) processInventories() {
+	starts := make(map[float32]bool)
+	for _, fight := range r.Fights {
+		starts[fight.Start] = true
+	}
+
+	snapshots := []*InventorySnapshot{}
+	for _, s := range r.Inventories {
+		if s.Reason == InventoryFight && !starts[s.trigger] {
+			continue
+		}
+
+		s.GameID = r.GameID
+		s.SteamID = r.Players[s.Hero]
+		snapshots = append(snapshots, s)
+	}
+
+	r.Inventories = snapshots
+}
@@ -0,0 +1,247 @@
+package secretshop
+
+import (
+	"strings"
+
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// Ways an item can leave a hero's inventory
+const (
+	ItemSold         = "sold"
+	ItemConsumed     = "consumed"
+	ItemCombined     = "combined"
+	ItemDisassembled = "disassembled"
+	ItemDropped      = "dropped"
+)
+
+// sameMoment is how close, in seconds, two events need to be to count as
+// happening at the same time. Entity updates and the combat log aren't always
+// on the same tick
+const sameMoment = 0.5
+
+// ItemLifecycle contains information about a single item from the moment a
+// hero got it to the moment it was sold, used up, combined into another item or
+// dropped. An item which is given away or picked up by another hero starts a
+// new lifecycle for its new owner
+type ItemLifecycle struct {
+	GameID    uint64  `json:"gameId"`
+	SteamID   uint64  `json:"steamId"`
+	Hero      string  `json:"hero"`
+	Item      string  `json:"item"`
+	Acquired  float32 `json:"acquired"`
+	GameTime  float32 `json:"gameTime"`
+	Uses      int     `json:"uses"`
+	Removed   float32 `json:"removed,omitempty"`
+	RemovedBy string  `json:"removedBy,omitempty"`
+
+	charges    int32
+	hasCharges bool
+	previous   *ItemLifecycle
+}
+
+// parseItemEntity follows item entities carried by heroes, starting a
+// lifecycle whenever a hero gets an item and ending it when the item is
+// deleted or leaves the hero
+func (r *Replay) parseItemEntity(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	class := e.GetClassName()
+	if class == "CDOTA_Item_Physical" {
+		return r.parseGroundItem(p, e, op)
+	}
+
+	if !strings.HasPrefix(class, "CDOTA_Item") || class == "CDOTA_Item_Rune" {
+		return nil
+	}
+
+	index := e.GetIndex()
+	item, ok := r.liveItems[index]
+	if op.Flag(manta.EntityOpDeleted) {
+		if ok {
+			item.Removed = r.gameTime
+			delete(r.liveItems, index)
+		}
+		delete(r.groundItems, index)
+		delete(r.droppedItems, index)
+		return nil
+	}
+
+	hero := ""
+	if owner := entityOwner(p, e); isHero(owner) {
+		hero = entityName(p, owner)
+	}
+
+	// Items change owner when they're given away or picked up by another hero
+	if ok && item.Hero != hero {
+		r.dropItem(index)
+		ok = false
+	}
+
+	if !ok {
+		if hero == "" || r.groundItems[index] {
+			return nil
+		}
+		item = r.acquireItem(p, e, hero)
+	}
+
+	if charges, ok := e.GetInt32("m_iCurrentCharges"); ok {
+		item.charges = charges
+		item.hasCharges = true
+	}
+
+	return nil
+}
+
+// parseGroundItem follows the entities holding items that are lying on the
+// ground, so that items dropped and picked up again by the same hero are seen
+func (r *Replay) parseGroundItem(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	handle, ok := e.GetUint32("m_hItem")
+	if !ok {
+		return nil
+	}
+
+	item := p.FindEntityByHandle(uint64(handle))
+	if item == nil {
+		return nil
+	}
+	index := item.GetIndex()
+
+	if op.Flag(manta.EntityOpCreated) {
+		r.groundItems[index] = true
+		r.dropItem(index)
+	}
+
+	if op.Flag(manta.EntityOpDeleted) {
+		delete(r.groundItems, index)
+		if _, ok := r.liveItems[index]; ok {
+			return nil
+		}
+
+		if owner := entityOwner(p, item); isHero(owner) {
+			r.acquireItem(p, item, entityName(p, owner))
+		}
+	}
+
+	return nil
+}
+
+// acquireItem starts the lifecycle of an item a hero has just got
+func (r *Replay) acquireItem(p *manta.Parser, e *manta.Entity, hero string) *ItemLifecycle {
+	index := e.GetIndex()
+	item := &ItemLifecycle{
+		Hero:     hero,
+		Item:     entityName(p, e),
+		Acquired: r.gameTime,
+		previous: r.droppedItems[index],
+	}
+	delete(r.droppedItems, index)
+
+	r.ItemLifecycles = append(r.ItemLifecycles, item)
+	r.liveItems[index] = item
+	return item
+}
+
+// dropItem ends the lifecycle of an item that has left its hero without being
+// deleted, by being dropped or given away
+func (r *Replay) dropItem(index int32) {
+	item, ok := r.liveItems[index]
+	if !ok {
+		return
+	}
+
+	item.Removed = r.gameTime
+	item.RemovedBy = ItemDropped
+	delete(r.liveItems, index)
+	r.droppedItems[index] = item
+}
+
+// parseItemUse counts an item being used against the oldest matching item the
+// hero is carrying
+func (r *Replay) parseItemUse(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	hero, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetAttackerName()))
+	name, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetInflictorName()))
+
+	var oldest *ItemLifecycle
+	for _, item := range r.liveItems {
+		if item.Hero != hero || item.Item != name {
+			continue
+		}
+
+		if oldest == nil || item.Acquired < oldest.Acquired {
+			oldest = item
+		}
+	}
+
+	if oldest != nil {
+		oldest.Uses++
+	}
+}
+
+// processItemLifecycles works out why each removed item left its hero's
+// inventory. Items are sold if the hero was paid for selling an item at the
+// same moment, consumed if they ran out of charges or nothing replaced them,
+// and otherwise combined or disassembled depending on whether more items were
+// removed or created for the hero at that moment
+func (r *Replay) processItemLifecycles() {
+	for _, item := range r.ItemLifecycles {
+		item.GameID = r.GameID
+		item.SteamID = r.Players[item.Hero]
+
+		if item.Removed == 0 || item.RemovedBy != "" {
+			continue
+		}
+
+		if r.soldItemAt(item.Hero, item.Removed) {
+			item.RemovedBy = ItemSold
+			continue
+		}
+
+		if item.hasCharges && item.charges == 0 {
+			item.RemovedBy = ItemConsumed
+			continue
+		}
+
+		created, removed := 0, 0
+		for _, other := range r.ItemLifecycles {
+			if other.Hero != item.Hero {
+				continue
+			}
+
+			if abs32(other.Acquired-item.Removed) <= sameMoment {
+				created++
+			}
+
+			if other.Removed != 0 && other.RemovedBy != ItemDropped && abs32(other.Removed-item.Removed) <= sameMoment {
+				removed++
+			}
+		}
+
+		switch {
+		case created == 0:
+			item.RemovedBy = ItemConsumed
+		case created > removed:
+			item.RemovedBy = ItemDisassembled
+		default:
+			item.RemovedBy = ItemCombined
+		}
+	}
+}
+
+// soldItemAt returns true if a hero was paid for selling an item at a time
+func (r *Replay) soldItemAt(hero string, t float32) bool {
+	for _, change := range r.goldChanges {
+		if change.Hero == hero && change.Reason == goldReasonSellItem && abs32(change.Timestamp-t) <= sameMoment {
+			return true
+		}
+	}
+
+	return false
+}
+
+func abs32(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+
+	return f
+}
@@ -0,0 +1,174 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// buybackCooldown is how long, in seconds, a player has to wait between buybacks
+const buybackCooldown = 480
+
+// Reasons given for a DOTA_COMBATLOG_GOLD entry, from EDOTA_ModifyGold_Reason
+const (
+	goldReasonDeath              = 1
+	goldReasonBuyback            = 2
+	goldReasonPurchaseConsumable = 3
+	goldReasonPurchaseItem       = 4
+	goldReasonSellItem           = 6
+)
+
+// KillEvent contains information about a hero being killed
+type KillEvent struct {
+	GameID          uint64   `json:"gameId"`
+	Killer          string   `json:"killer"`
+	KillerSteamID   uint64   `json:"killerSteamId"`
+	Victim          string   `json:"victim"`
+	VictimSteamID   uint64   `json:"victimSteamId"`
+	Assisters       []string `json:"assisters"`
+	Timestamp       float32  `json:"timestamp"`
+	GameTime        float32  `json:"gameTime"`
+	BuybackEligible bool     `json:"buybackEligible"`
+	GoldLost        uint32   `json:"goldLost"`
+	DeathDuration   float32  `json:"deathDuration"`
+
+	assistIDs []int32
+}
+
+// respawn is a hero coming back to life after being dead
+type respawn struct {
+	Hero      string
+	Timestamp float32
+}
+
+// goldChange is a single change to a hero's gold from the combat log
+type goldChange struct {
+	Hero      string
+	Reason    uint32
+	Amount    int32
+	Timestamp float32
+}
+
+// parseKill records a hero death from the combat log, illusions are ignored
+func (r *Replay) parseKill(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	if !m.GetIsTargetHero() || m.GetIsTargetIllusion() {
+		return
+	}
+
+	killer, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetAttackerName()))
+	victim, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetTargetName()))
+
+	kill := KillEvent{
+		Killer:    killer,
+		Victim:    victim,
+		Timestamp: m.GetTimestamp(),
+		assistIDs: m.GetAssistPlayers(),
+	}
+
+	r.Kills = append(r.Kills, &kill)
+}
+
+// parseLifeState records heroes respawning, so that the time they spent dead
+// can be worked out for each kill. Heroes are dead while m_lifeState isn't 0
+func (r *Replay) parseLifeState(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	if !isHero(e) {
+		return nil
+	}
+
+	index := e.GetIndex()
+	if op.Flag(manta.EntityOpDeleted) {
+		delete(r.lifeStates, index)
+		return nil
+	}
+
+	state, ok := e.GetInt32("m_lifeState")
+	if !ok {
+		return nil
+	}
+
+	last, seen := r.lifeStates[index]
+	r.lifeStates[index] = state
+	if seen && last != 0 && state == 0 {
+		r.respawns = append(r.respawns, respawn{
+			Hero:      entityName(p, e),
+			Timestamp: r.gameTime,
+		})
+	}
+
+	return nil
+}
+
+// parseGold records changes to a hero's gold so that they can be matched up
+// with deaths and purchases once parsing has finished
+func (r *Replay) parseGold(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	hero, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetTargetName()))
+
+	r.goldChanges = append(r.goldChanges, goldChange{
+		Hero:      hero,
+		Reason:    m.GetGoldReason(),
+		Amount:    int32(m.GetValue()),
+		Timestamp: m.GetTimestamp(),
+	})
+}
+
+// goldSpent returns the gold a hero lost for a reason at a point in time
+func (r *Replay) goldSpent(hero string, reason uint32, timestamp float32) uint32 {
+	var spent int32
+	for _, change := range r.goldChanges {
+		if change.Hero == hero && change.Reason == reason && change.Timestamp == timestamp {
+			if change.Amount < 0 {
+				spent -= change.Amount
+			} else {
+				spent += change.Amount
+			}
+		}
+	}
+
+	return uint32(spent)
+}
+
+// processKills fills in steam ids, assisters, gold lost, time spent dead and
+// buyback eligibility for each kill
+func (r *Replay) processKills() {
+	for _, k := range r.Kills {
+		k.GameID = r.GameID
+		k.KillerSteamID = r.Players[k.Killer]
+		k.VictimSteamID = r.Players[k.Victim]
+		k.GoldLost = r.goldSpent(k.Victim, goldReasonDeath, k.Timestamp)
+
+		k.Assisters = []string{}
+		for _, id := range k.assistIDs {
+			if hero := r.heroByPlayerID(id); hero != "" {
+				k.Assisters = append(k.Assisters, hero)
+			}
+		}
+
+		k.BuybackEligible = true
+		for _, b := range r.Buybacks {
+			if b.Hero != k.Victim {
+				continue
+			}
+
+			if b.Timestamp <= k.Timestamp && k.Timestamp-b.Timestamp < buybackCooldown {
+				k.BuybackEligible = false
+				break
+			}
+		}
+
+		for _, respawn := range r.respawns {
+			if respawn.Hero == k.Victim && respawn.Timestamp >= k.Timestamp {
+				k.DeathDuration = respawn.Timestamp - k.Timestamp
+				break
+			}
+		}
+	}
+}
+
+// heroByPlayerID returns the hero played by a player id, as used by the
+// combat log, or an empty string if there isn't one
+func (r *Replay) heroByPlayerID(id int32) string {
+	if id < 0 || int(id) >= len(r.playerHeroes) {
+		return ""
+	}
+
+	return r.playerHeroes[id]
+}
@@ -0,0 +1,278 @@
+package secretshop
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// ParserModule extracts something from a replay. Register is called before
+// parsing starts to add callbacks to the parser, and Finalize once parsing has
+// finished to fill in anything that needs the whole replay. Modules outside of
+// this package can keep their results in Replay.Extensions
+type ParserModule interface {
+	Register(p *manta.Parser, r *Replay) error
+	Finalize(r *Replay)
+}
+
+// ModuleFactory creates a new instance of a module for each replay parsed
+type ModuleFactory func() ParserModule
+
+// ModuleGameState is always run, as every other module relies on the match id,
+// players, game state and gold changes it reads
+const ModuleGameState = "gamestate"
+
+var (
+	modulesMu      sync.RWMutex
+	modules        = make(map[string]ModuleFactory)
+	moduleRequires = make(map[string][]string)
+	moduleOrder    []string
+)
+
+// RegisterModule makes a parser module available by name, along with the
+// modules it needs the results of. Modules are run in the order they're
+// registered, so required modules have to be registered first, and turning a
+// module on turns on everything it requires. Registering the same name twice,
+// or requiring a module that isn't registered, panics
+func RegisterModule(name string, factory ModuleFactory, requires ...string) {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+
+	if factory == nil {
+		panic("secretshop: RegisterModule factory is nil")
+	}
+	if _, dup := modules[name]; dup {
+		panic("secretshop: RegisterModule called twice for module " + name)
+	}
+	for _, required := range requires {
+		if _, ok := modules[required]; !ok {
+			panic("secretshop: RegisterModule module " + name + " requires unregistered module " + required)
+		}
+	}
+
+	modules[name] = factory
+	moduleRequires[name] = requires
+	moduleOrder = append(moduleOrder, name)
+}
+
+// Modules returns the names of every registered module, sorted
+func Modules() []string {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+
+	names := make([]string, len(moduleOrder))
+	copy(names, moduleOrder)
+	sort.Strings(names)
+	return names
+}
+
+// CheckModules returns an error if any of a list of modules isn't registered
+func CheckModules(names []string) error {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+
+	for _, name := range names {
+		if _, ok := modules[name]; !ok {
+			return fmt.Errorf("unknown parser module [%s]", name)
+		}
+	}
+
+	return nil
+}
+
+// newModules creates the modules to run for a replay in registration order,
+// along with every module they require. Every module is run if none are asked for
+func newModules(names []string) ([]ParserModule, error) {
+	if err := CheckModules(names); err != nil {
+		return nil, err
+	}
+
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+
+	enabled := make(map[string]bool)
+	var enable func(name string)
+	enable = func(name string) {
+		if enabled[name] {
+			return
+		}
+		enabled[name] = true
+		for _, required := range moduleRequires[name] {
+			enable(required)
+		}
+	}
+
+	enable(ModuleGameState)
+	for _, name := range names {
+		enable(name)
+	}
+
+	active := []ParserModule{}
+	for _, name := range moduleOrder {
+		if len(names) == 0 || enabled[name] {
+			active = append(active, modules[name]())
+		}
+	}
+
+	return active, nil
+}
+
+// module adapts the built in extractors, which keep their state on the replay,
+// to the ParserModule interface
+type module struct {
+	register func(p *manta.Parser, r *Replay)
+	finalize func(r *Replay)
+}
+
+func (m module) Register(p *manta.Parser, r *Replay) error {
+	if m.register != nil {
+		m.register(p, r)
+	}
+	return nil
+}
+
+func (m module) Finalize(r *Replay) {
+	if m.finalize != nil {
+		m.finalize(r)
+	}
+}
+
+// builtin registers a module made from functions on the replay
+func builtin(name string, requires []string, register func(p *manta.Parser, r *Replay), finalize func(r *Replay)) {
+	RegisterModule(name, func() ParserModule {
+		return module{register: register, finalize: finalize}
+	}, requires...)
+}
+
+// onCombatLog adds a callback for combat log entries of the given types
+func onCombatLog(p *manta.Parser, fn func(*manta.Parser, *dota.CMsgDOTACombatLogEntry), types ...dota.DOTA_COMBATLOG_TYPES) {
+	p.Callbacks.OnCMsgDOTACombatLogEntry(func(m *dota.CMsgDOTACombatLogEntry) error {
+		t := m.GetType()
+		for _, want := range types {
+			if t == want {
+				fn(p, m)
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// onEntity adds a callback for entity changes
+func onEntity(p *manta.Parser, fn func(*manta.Parser, *manta.Entity, manta.EntityOp) error) {
+	p.OnEntity(func(e *manta.Entity, op manta.EntityOp) error {
+		return fn(p, e, op)
+	})
+}
+
+// onChatEvent adds a callback for chat events, which announce things like the
+// Aegis being taken and runes being picked up
+func onChatEvent(p *manta.Parser, fn func(*dota.CDOTAUserMsg_ChatEvent)) {
+	p.Callbacks.OnCDOTAUserMsg_ChatEvent(func(m *dota.CDOTAUserMsg_ChatEvent) error {
+		fn(m)
+		return nil
+	})
+}
+
+// The built in modules, registered in an order that satisfies the
+// dependencies between them
+func init() {
+	builtin(ModuleGameState, nil, func(p *manta.Parser, r *Replay) {
+		onCombatLog(p, r.parseGameState, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_GAME_STATE)
+		onCombatLog(p, r.parseGold, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_GOLD)
+		p.Callbacks.OnCDemoFileInfo(func(m *dota.CDemoFileInfo) error {
+			r.parseFileInfo(p, m)
+			return nil
+		})
+	}, (*Replay).processPlayerSlots)
+
+	builtin("purchases", nil, func(p *manta.Parser, r *Replay) {
+		onCombatLog(p, r.parsePurchase, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_PURCHASE)
+	}, (*Replay).processPurchases)
+
+	builtin("buybacks", []string{"purchases"}, func(p *manta.Parser, r *Replay) {
+		onCombatLog(p, r.parseBuyback, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_BUYBACK)
+		onEntity(p, r.parseBuybackGold)
+	}, (*Replay).processBuybacks)
+
+	builtin("kills", []string{"buybacks"}, func(p *manta.Parser, r *Replay) {
+		onCombatLog(p, r.parseKill, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_DEATH)
+		onEntity(p, r.parseLifeState)
+	}, (*Replay).processKills)
+
+	builtin("items", nil, func(p *manta.Parser, r *Replay) {
+		onCombatLog(p, r.parseItemUse, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_ITEM)
+		onEntity(p, r.parseItemEntity)
+	}, (*Replay).processItemLifecycles)
+
+	builtin("assemblies", []string{"purchases", "items"}, nil, (*Replay).processAssemblies)
+
+	builtin("timeline", nil, func(p *manta.Parser, r *Replay) {
+		onEntity(p, r.parseTimeline)
+	}, (*Replay).processTimeline)
+
+	builtin("skills", nil, func(p *manta.Parser, r *Replay) {
+		onEntity(p, r.parseAbilityEntity)
+	}, (*Replay).processSkillBuilds)
+
+	builtin("wards", nil, func(p *manta.Parser, r *Replay) {
+		onCombatLog(p, r.parseWardDeath, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_DEATH)
+		onEntity(p, r.parseWardEntity)
+	}, (*Replay).processWards)
+
+	builtin("positions", nil, func(p *manta.Parser, r *Replay) {
+		onEntity(p, r.parsePositions)
+	}, (*Replay).processPositions)
+
+	builtin("objectives", []string{"items"}, func(p *manta.Parser, r *Replay) {
+		onCombatLog(p, r.parseObjectiveKill, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_DEATH)
+		onChatEvent(p, r.parseAegisEvent)
+	}, (*Replay).processObjectives)
+
+	builtin("runes", nil, func(p *manta.Parser, r *Replay) {
+		onChatEvent(p, r.parseRuneEvent)
+		onEntity(p, r.parseRuneEntity)
+	}, (*Replay).processRunes)
+
+	builtin("gold", []string{"purchases", "buybacks", "kills"}, nil, (*Replay).processGoldSpending)
+
+	builtin("damage", nil, func(p *manta.Parser, r *Replay) {
+		onCombatLog(p, r.parseDamage, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_DAMAGE, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_HEAL)
+	}, (*Replay).processDamage)
+
+	builtin("fights", []string{"purchases", "kills"}, func(p *manta.Parser, r *Replay) {
+		onCombatLog(p, r.parseHeroDamage, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_DAMAGE)
+	}, (*Replay).processFights)
+
+	builtin("chat", nil, func(p *manta.Parser, r *Replay) {
+		if !r.CaptureChat {
+			return
+		}
+
+		p.Callbacks.OnCDOTAUserMsg_ChatMessage(func(m *dota.CDOTAUserMsg_ChatMessage) error {
+			r.parseChatMessage(m)
+			return nil
+		})
+		p.Callbacks.OnCUserMessageSayText2(func(m *dota.CUserMessageSayText2) error {
+			r.parseSayText(m)
+			return nil
+		})
+		p.Callbacks.OnCDOTAUserMsg_ChatWheel(func(m *dota.CDOTAUserMsg_ChatWheel) error {
+			r.parseChatWheel(m)
+			return nil
+		})
+	}, (*Replay).processChat)
+
+	builtin("deliveries", []string{"purchases", "items"}, func(p *manta.Parser, r *Replay) {
+		onEntity(p, r.parseDeliveries)
+	}, (*Replay).processDeliveries)
+
+	builtin("inventory", []string{"fights"}, func(p *manta.Parser, r *Replay) {
+		r.snapshots = true
+		onCombatLog(p, r.parseInventoryDeath, dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_DEATH)
+		onEntity(p, r.parseInventory)
+	}, (*Replay).processInventories)
+}
@@ -0,0 +1,130 @@
+package secretshop
+
+import (
+	"sort"
+
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// Types of objective event
+const (
+	ObjectiveBuilding       = "building"
+	ObjectiveRoshan         = "roshan"
+	ObjectiveAegis          = "aegis"
+	ObjectiveAegisStolen    = "aegis_stolen"
+	ObjectiveAegisDenied    = "aegis_denied"
+	ObjectiveCheese         = "cheese"
+	ObjectiveRefresherShard = "refresher_shard"
+)
+
+// roshanDrops maps the items Roshan drops, other than the Aegis, to the type of
+// objective event they're recorded as when picked up
+var roshanDrops = map[string]string{
+	"item_cheese":          ObjectiveCheese,
+	"item_refresher_shard": ObjectiveRefresherShard,
+}
+
+// aegisEvents maps the chat events sent about the Aegis to the type of
+// objective event they're recorded as
+var aegisEvents = map[dota.DOTA_CHAT_MESSAGE]string{
+	dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_AEGIS:        ObjectiveAegis,
+	dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_AEGIS_STOLEN: ObjectiveAegisStolen,
+	dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_DENIED_AEGIS: ObjectiveAegisDenied,
+}
+
+// ObjectiveEvent contains information about a building or Roshan being killed,
+// or the Aegis and Roshan's other drops being taken. Hero is the unit that
+// killed the objective or took the item, and Team the side it played for
+type ObjectiveEvent struct {
+	GameID    uint64  `json:"gameId"`
+	Type      string  `json:"type"`
+	Target    string  `json:"target"`
+	Hero      string  `json:"hero"`
+	SteamID   uint64  `json:"steamId"`
+	Team      int32   `json:"team"`
+	Timestamp float32 `json:"timestamp"`
+	GameTime  float32 `json:"gameTime"`
+
+	playerID int32
+}
+
+// parseObjectiveKill records buildings and Roshan dying from the combat log
+func (r *Replay) parseObjectiveKill(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	target, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetTargetName()))
+
+	objective := ObjectiveBuilding
+	if target == "npc_dota_roshan" {
+		objective = ObjectiveRoshan
+	} else if !m.GetIsTargetBuilding() {
+		return
+	}
+
+	attacker, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetAttackerName()))
+	r.Objectives = append(r.Objectives, &ObjectiveEvent{
+		Type:      objective,
+		Target:    target,
+		Hero:      attacker,
+		Team:      int32(m.GetAttackerTeam()),
+		Timestamp: m.GetTimestamp(),
+		playerID:  -1,
+	})
+}
+
+// parseAegisEvent records the Aegis being picked up, stolen or denied from the
+// chat events announcing it, the hero is looked up from the player id later
+func (r *Replay) parseAegisEvent(m *dota.CDOTAUserMsg_ChatEvent) {
+	objective, ok := aegisEvents[m.GetType()]
+	if !ok {
+		return
+	}
+
+	r.Objectives = append(r.Objectives, &ObjectiveEvent{
+		Type:      objective,
+		Target:    "item_aegis",
+		Timestamp: r.gameTime,
+		playerID:  m.GetPlayerid_1(),
+	})
+}
+
+// processObjectives fills in the heroes behind Aegis events, adds Roshan's
+// other drops from the item lifecycles and sorts the events by time. Roshan's
+// drops start on the ground, so their first lifecycle starts when a hero picks
+// them up and later ones are the item being passed around
+func (r *Replay) processObjectives() {
+	for _, item := range r.ItemLifecycles {
+		objective, ok := roshanDrops[item.Item]
+		if !ok || item.Hero == "" || item.previous != nil {
+			continue
+		}
+
+		r.Objectives = append(r.Objectives, &ObjectiveEvent{
+			Type:      objective,
+			Target:    item.Item,
+			Hero:      item.Hero,
+			Timestamp: item.Acquired,
+			playerID:  -1,
+		})
+	}
+
+	sides := make(map[string]int32)
+	for _, slot := range r.PlayerSlots {
+		sides[slot.Hero] = slot.gameTeam
+	}
+
+	for _, o := range r.Objectives {
+		o.GameID = r.GameID
+		if o.playerID >= 0 {
+			o.Hero = r.heroByPlayerID(o.playerID)
+		}
+		o.SteamID = r.Players[o.Hero]
+
+		if side, ok := sides[o.Hero]; ok && o.Team == 0 {
+			o.Team = side
+		}
+	}
+
+	sort.SliceStable(r.Objectives, func(i, j int) bool {
+		return r.Objectives[i].Timestamp < r.Objectives[j].Timestamp
+	})
+}
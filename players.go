@@ -0,0 +1,113 @@
+package secretshop
+
+import (
+	"fmt"
+
+	"github.com/dotabuff/manta"
+)
+
+// Sides a player can play on
+const (
+	SideRadiant = "Radiant"
+	SideDire    = "Dire"
+)
+
+// PlayerSlot contains information about the player in a slot for a single
+// match, including the side and team they played for
+type PlayerSlot struct {
+	GameID   uint64 `json:"gameId"`
+	SteamID  uint64 `json:"steamId"`
+	PlayerID int32  `json:"playerId"`
+	Hero     string `json:"hero"`
+	Side     string `json:"side"`
+	TeamID   uint32 `json:"teamId,omitempty"`
+	TeamTag  string `json:"teamTag,omitempty"`
+	TeamName string `json:"teamName,omitempty"`
+
+	gameTeam int32
+}
+
+// team is a pro team read from the team entities
+type team struct {
+	Name string
+	Tag  string
+}
+
+// parsePlayerResource reads player ids and team names from the entities left
+// once the replay has finished
+func (r *Replay) parsePlayerResource(p *manta.Parser) {
+	if pr := findEntity(p, "CDOTA_PlayerResource"); pr != nil {
+		for i := 0; i < maxPlayers; i++ {
+			steamID, ok := pr.GetUint64(fmt.Sprintf("m_vecPlayerData.%04d.m_iPlayerSteamID", i))
+			if ok && steamID != 0 {
+				r.playerIDs[steamID] = int32(i)
+			}
+		}
+	}
+
+	for _, e := range p.FilterEntity(func(e *manta.Entity) bool { return e.GetClassName() == "CDOTATeam" }) {
+		number, _ := e.GetInt32("m_iTeamNum")
+		name, _ := e.GetString("m_szTeamname")
+		tag, _ := e.GetString("m_szTag")
+		r.teams[number] = team{Name: name, Tag: tag}
+	}
+}
+
+// processPlayerSlots works out the side and team each player played for. The
+// order of the game info is used for player ids if they couldn't be read from
+// the player resource
+func (r *Replay) processPlayerSlots() {
+	heroes := []string{}
+	for i, slot := range r.PlayerSlots {
+		slot.GameID = r.GameID
+		slot.PlayerID = int32(i)
+		if id, ok := r.playerIDs[slot.SteamID]; ok {
+			slot.PlayerID = id
+		}
+
+		switch slot.gameTeam {
+		case teamRadiant:
+			slot.Side = SideRadiant
+		case teamDire:
+			slot.Side = SideDire
+		}
+
+		if r.Draft != nil {
+			if slot.gameTeam == teamRadiant {
+				slot.TeamID, slot.TeamTag = r.Draft.RadiantTeamID, r.Draft.RadiantTeamTag
+			} else if slot.gameTeam == teamDire {
+				slot.TeamID, slot.TeamTag = r.Draft.DireTeamID, r.Draft.DireTeamTag
+			}
+		}
+
+		if t, ok := r.teams[slot.gameTeam]; ok {
+			slot.TeamName = t.Name
+			if slot.TeamTag == "" {
+				slot.TeamTag = t.Tag
+			}
+		}
+
+		for int(slot.PlayerID) >= len(heroes) {
+			heroes = append(heroes, "")
+		}
+		heroes[slot.PlayerID] = slot.Hero
+	}
+	r.playerHeroes = heroes
+
+	for _, player := range r.PlayerInfo {
+		for _, slot := range r.PlayerSlots {
+			if slot.SteamID != player.SteamID {
+				continue
+			}
+
+			switch {
+			case slot.TeamName != "":
+				player.Team = slot.TeamName
+			case slot.TeamTag != "":
+				player.Team = slot.TeamTag
+			default:
+				player.Team = slot.Side
+			}
+		}
+	}
+}
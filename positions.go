@@ -0,0 +1,179 @@
+package secretshop
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dotabuff/manta"
+)
+
+// Encoded paths start with a version byte followed by the points, each point
+// takes pathPointSize bytes
+const (
+	pathVersion   = 1
+	pathPointSize = 12
+)
+
+// HeroPath contains the positions of a player's hero sampled through a match
+type HeroPath struct {
+	GameID  uint64      `json:"gameId"`
+	SteamID uint64      `json:"steamId"`
+	Hero    string      `json:"hero"`
+	Points  []PathPoint `json:"points"`
+}
+
+// PathPoint is a hero's position in world coordinates at a point in the game,
+// coordinates are rounded to whole units so they can be stored as int16s
+type PathPoint struct {
+	Timestamp float32 `json:"timestamp"`
+	GameTime  float32 `json:"gameTime"`
+	X         int16   `json:"x"`
+	Y         int16   `json:"y"`
+}
+
+// HeatmapCell contains the number of samples a hero spent in a square of the
+// map, X and Y are the bottom left corner of the square
+type HeatmapCell struct {
+	X     int32 `json:"x"`
+	Y     int32 `json:"y"`
+	Count int   `json:"count"`
+}
+
+// parsePositions samples the position of every player's hero each time the
+// parser passes the position interval, sampling is off unless an interval is set
+func (r *Replay) parsePositions(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	if e.GetClassName() != "CDOTAGamerulesProxy" || r.PositionInterval == 0 {
+		return nil
+	}
+
+	if p.Tick < r.nextPosition {
+		return nil
+	}
+	r.nextPosition = p.Tick + r.PositionInterval
+
+	pr := findEntity(p, "CDOTA_PlayerResource")
+	if pr == nil {
+		return nil
+	}
+
+	for i := 0; i < maxPlayers; i++ {
+		handle, ok := pr.GetUint32(fmt.Sprintf("m_vecPlayerTeamData.%04d.m_hSelectedHero", i))
+		if !ok {
+			continue
+		}
+
+		hero := p.FindEntityByHandle(uint64(handle))
+		if !isHero(hero) {
+			continue
+		}
+
+		x, y, ok := entityPosition(hero)
+		if !ok {
+			continue
+		}
+
+		name := entityName(p, hero)
+		path, ok := r.paths[name]
+		if !ok {
+			path = &HeroPath{Hero: name, Points: []PathPoint{}}
+			r.paths[name] = path
+			r.Paths = append(r.Paths, path)
+		}
+
+		path.Points = append(path.Points, PathPoint{
+			Timestamp: r.gameTime,
+			X:         int16(x),
+			Y:         int16(y),
+		})
+	}
+
+	return nil
+}
+
+// processPositions fills in the game and steam id for each path
+func (r *Replay) processPositions() {
+	for _, path := range r.Paths {
+		path.GameID = r.GameID
+		path.SteamID = r.Players[path.Hero]
+	}
+}
+
+// Window returns the points of a path between two game clock times
+func (h HeroPath) Window(from, to float32) []PathPoint {
+	points := []PathPoint{}
+	for _, point := range h.Points {
+		if point.GameTime < from || point.GameTime > to {
+			continue
+		}
+		points = append(points, point)
+	}
+
+	return points
+}
+
+// Heatmap counts how many points of a set of paths fall into each square of a
+// grid over the map, only squares with at least one point are returned
+func Heatmap(paths []HeroPath, from, to float32, cellSize int32) []HeatmapCell {
+	counts := make(map[[2]int32]int)
+	cells := []HeatmapCell{}
+	for _, path := range paths {
+		for _, point := range path.Window(from, to) {
+			key := [2]int32{floorCell(int32(point.X), cellSize), floorCell(int32(point.Y), cellSize)}
+			if _, ok := counts[key]; !ok {
+				cells = append(cells, HeatmapCell{X: key[0], Y: key[1]})
+			}
+			counts[key]++
+		}
+	}
+
+	for i := range cells {
+		cells[i].Count = counts[[2]int32{cells[i].X, cells[i].Y}]
+	}
+
+	return cells
+}
+
+// floorCell rounds a coordinate down to the corner of the grid square it's in
+func floorCell(v, size int32) int32 {
+	if v < 0 {
+		return ((v - size + 1) / size) * size
+	}
+	return (v / size) * size
+}
+
+// EncodePath packs path points into a byte slice for storage after the version
+// byte, each point takes 12 bytes: the timestamp and game time as float32s and
+// the coordinates as int16s
+func EncodePath(points []PathPoint) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 1+len(points)*pathPointSize))
+	buf.WriteByte(pathVersion)
+	for _, point := range points {
+		binary.Write(buf, binary.LittleEndian, point)
+	}
+
+	return buf.Bytes()
+}
+
+// DecodePath unpacks path points written by EncodePath
+func DecodePath(data []byte) ([]PathPoint, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("path data is empty")
+	}
+
+	if data[0] != pathVersion {
+		return nil, fmt.Errorf("path data has unknown version %d", data[0])
+	}
+
+	data = data[1:]
+	if len(data)%pathPointSize != 0 {
+		return nil, fmt.Errorf("path data is %d bytes, not a multiple of %d", len(data), pathPointSize)
+	}
+
+	points := make([]PathPoint, len(data)/pathPointSize)
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, points); err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
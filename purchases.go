@@ -0,0 +1,30 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// parsePurchase records an item being bought from the combat log
+func (r *Replay) parsePurchase(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	item, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetValue()))
+	hero, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetTargetName()))
+	timestamp := m.GetTimestamp()
+
+	purchase := ItemPurchase{
+		Item:      item,
+		Hero:      hero,
+		Timestamp: timestamp,
+		Raw:       m,
+	}
+
+	r.ItemPurchases = append(r.ItemPurchases, &purchase)
+}
+
+// processPurchases fills in the game and steam id for each purchase
+func (r *Replay) processPurchases() {
+	for _, p := range r.ItemPurchases {
+		p.GameID = r.GameID
+		p.SteamID = r.Players[p.Hero]
+	}
+}
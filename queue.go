@@ -0,0 +1,647 @@
+package secretshop
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JobStatus describes how far a replay has made it through the ingestion queue
+type JobStatus string
+
+// Possible states for a queued replay
+const (
+	JobQueued  JobStatus = "queued"
+	JobParsing JobStatus = "parsing"
+	JobSaving  JobStatus = "saving"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+var (
+	// ErrQueueFull is returned when a replay is submitted to a queue with no free slots
+	ErrQueueFull = errors.New("replay queue is full")
+
+	// ErrDuplicateReplay is returned when a replay has already been saved to a store
+	ErrDuplicateReplay = errors.New("replay has already been parsed")
+)
+
+// Job contains information about a replay waiting to be, or being, parsed
+type Job struct {
+	ID       string    `json:"id"`
+	FileName string    `json:"fileName"`
+	Path     string    `json:"-"`
+	Status   JobStatus `json:"status"`
+	GameID   uint64    `json:"gameId,omitempty"`
+	Modules  []string  `json:"modules,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+
+	// Progress is only reported while a job is being parsed and is not stored
+	Progress *ParseProgress `json:"progress,omitempty"`
+}
+
+// Queue parses uploaded replays in the background using a bounded pool of workers
+type Queue struct {
+	conf   Config
+	jobs   chan *Job
+	quit   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	active map[string]*Job
+}
+
+// NewQueue creates a queue using the worker and spool settings from a config
+func NewQueue(conf Config) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		conf:   conf,
+		jobs:   make(chan *Job, conf.QueueSize),
+		quit:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+		active: make(map[string]*Job),
+	}
+}
+
+// Start requeues any jobs left unfinished by a previous run and starts the workers
+func (q *Queue) Start() error {
+	pending, err := q.unfinishedJobs()
+	if err != nil {
+		return err
+	}
+
+	sources := make(chan Source)
+	results := ParseStream(q.ctx, sources, ParseOptions{
+		Workers: q.conf.Workers,
+		Timeout: time.Duration(q.conf.ParseTimeout) * time.Second,
+	})
+
+	q.wg.Add(1)
+	go q.feed(sources)
+	go q.collect(results)
+
+	if len(pending) > 0 {
+		log.Printf("Requeueing %d unfinished replay jobs", len(pending))
+	}
+
+	go func() {
+		for _, job := range pending {
+			q.track(job)
+			select {
+			case q.jobs <- job:
+			case <-q.quit:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop aborts any running parses and stops the workers, anything still queued
+// or cut short is picked up again on the next Start
+func (q *Queue) Stop() {
+	close(q.quit)
+	q.cancel()
+	q.wg.Wait()
+}
+
+// Submit spools a replay to disk and queues it for parsing with a list of
+// parser modules, the modules from the config are used if none are given.
+// Replays are spooled rather than parsed straight from the upload so that
+// queued jobs survive a restart
+func (q *Queue) Submit(fileName string, src io.Reader, modules []string) (*Job, error) {
+	if err := CheckModules(modules); err != nil {
+		return nil, err
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create job id: %s", err)
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		ID:       id,
+		FileName: fileName,
+		Modules:  modules,
+		Path:     filepath.Join(q.conf.SpoolDir, id+".dem"),
+		Status:   JobQueued,
+		Created:  now,
+		Updated:  now,
+	}
+
+	f, err := os.OpenFile(job.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("unable to spool replay: %s", err)
+	}
+
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		os.Remove(job.Path)
+		return nil, fmt.Errorf("unable to spool replay: %s", err)
+	}
+	f.Close()
+
+	if err := q.checkHeader(job); err != nil {
+		os.Remove(job.Path)
+		return nil, err
+	}
+
+	// The job has to be stored as queued before a worker can pick it up,
+	// otherwise a late save could put a finished job back in the queue
+	q.track(job)
+	q.save(job)
+
+	select {
+	case q.jobs <- job:
+	default:
+		q.update(job, JobFailed, ErrQueueFull)
+		return nil, ErrQueueFull
+	}
+
+	log.Printf("Queued replay [%s] as job [%s]", fileName, job.ID)
+
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// checkHeader reads the match id from the header of a spooled replay, refusing
+// replays which are already queued or saved before they get to the full parse.
+// Compressed replays, and replays with a header that can't be read, are left
+// to be checked once they have been parsed
+func (q *Queue) checkHeader(job *Job) error {
+	header, err := ReadHeader(job.Path)
+	if err == ErrNotReplay {
+		return err
+	} else if err == ErrCompressedReplay {
+		return nil
+	} else if err != nil {
+		log.Printf("Could not read header of replay [%s], it will be checked once parsed: %s", job.FileName, err)
+		return nil
+	}
+
+	if header.GameID == 0 {
+		return nil
+	}
+
+	host, err := duplicateStore(q.conf.Stores, header.GameID)
+	if err != nil {
+		return err
+	}
+
+	if host != "" {
+		log.Printf("Replay [%s] for game [%d] is already in store [%s]", job.FileName, header.GameID, host)
+		return ErrDuplicateReplay
+	}
+
+	return q.claim(job, header.GameID)
+}
+
+// claim tracks a job as the one parsing a game, refusing it if another job
+// already has the game. Checking and tracking happen under one lock so two
+// uploads of the same game can't both get through
+func (q *Queue) claim(job *Job, gameID uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, active := range q.active {
+		if active != job && active.GameID == gameID {
+			log.Printf("Replay [%s] for game [%d] is already queued as job [%s]", job.FileName, gameID, active.ID)
+			return ErrDuplicateReplay
+		}
+	}
+
+	job.GameID = gameID
+	q.active[job.ID] = job
+	return nil
+}
+
+// Job returns the current state of a job, checking the running queue before
+// falling back to the stores
+func (q *Queue) Job(id string) (Job, bool) {
+	q.mu.Lock()
+	job, ok := q.active[id]
+	if ok {
+		snapshot := *job
+		q.mu.Unlock()
+		return snapshot, true
+	}
+	q.mu.Unlock()
+
+	for host, store := range q.conf.Stores {
+		jobs, err := store.LoadJobs(map[string]interface{}{"id": []string{id}})
+		if err != nil {
+			log.Printf("Error loading job [%s] from store [%s]: %s", id, host, err)
+			continue
+		}
+
+		if len(jobs) > 0 {
+			return jobs[0], true
+		}
+	}
+
+	return Job{}, false
+}
+
+// feed hands queued jobs to the parser workers until the queue is stopped
+func (q *Queue) feed(sources chan<- Source) {
+	for {
+		select {
+		case <-q.quit:
+			return
+		case job := <-q.jobs:
+			select {
+			case sources <- q.source(job):
+			case <-q.quit:
+				return
+			}
+		}
+	}
+}
+
+// source opens a job's spooled replay for the parser workers, set up with the
+// parser settings from the config and the job's modules
+func (q *Queue) source(job *Job) Source {
+	return Source{
+		Name: job.ID,
+		Open: func() (*Replay, error) {
+			log.Printf("Parsing Replay [%s] for job [%s]...", job.FileName, job.ID)
+			q.update(job, JobParsing, nil)
+
+			replay, err := NewReplay(job.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			q.conf.Configure(replay)
+			if len(job.Modules) > 0 {
+				replay.Modules = job.Modules
+			}
+
+			replay.OnProgress = func(progress ParseProgress) {
+				q.mu.Lock()
+				job.Progress = &progress
+				q.mu.Unlock()
+			}
+
+			return replay, nil
+		},
+	}
+}
+
+// collect hands each replay to a saver as the workers finish parsing it. There
+// is a saver for every worker, so workers only wait on the stores once every
+// saver is busy
+func (q *Queue) collect(results <-chan ParseResult) {
+	defer q.wg.Done()
+
+	savers := q.conf.Workers
+	if savers <= 0 {
+		savers = 1
+	}
+	busy := make(chan struct{}, savers)
+
+	for result := range results {
+		q.mu.Lock()
+		job, ok := q.active[result.Source.Name]
+		q.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		busy <- struct{}{}
+		q.wg.Add(1)
+		go func(job *Job, result ParseResult) {
+			defer q.wg.Done()
+			defer func() { <-busy }()
+			q.finish(job, result)
+		}(job, result)
+	}
+}
+
+// finish saves a parsed replay and marks its job as done, or records why it
+// couldn't be parsed
+func (q *Queue) finish(job *Job, result ParseResult) {
+	if result.Err != nil {
+		if q.ctx.Err() != nil {
+			log.Printf("Stopped parsing replay [%s] for shutdown, job [%s] will be requeued", job.FileName, job.ID)
+			return
+		}
+
+		log.Printf("Error parsing replay [%s]: %s", job.FileName, result.Err)
+		q.update(job, JobFailed, result.Err)
+		return
+	}
+	replay := result.Replay
+
+	q.mu.Lock()
+	job.Progress = nil
+	q.mu.Unlock()
+
+	// Compressed replays are only claimed once parsed, so a duplicate still
+	// being saved by another job is caught here rather than by both saving
+	if replay.GameID != 0 {
+		if err := q.claim(job, replay.GameID); err != nil {
+			q.update(job, JobFailed, err)
+			return
+		}
+	}
+
+	log.Printf("Finished parsing Replay [%s], saving...", job.FileName)
+	q.update(job, JobSaving, nil)
+
+	if err := SaveReplay(q.conf.Stores, replay); err != nil {
+		log.Printf("Error saving replay [%s]: %s", job.FileName, err)
+		q.update(job, JobFailed, err)
+		return
+	}
+
+	log.Printf("Succesfully parsed and saved Replay [%s]. Read %d Purchases", job.FileName, len(replay.ItemPurchases))
+	q.update(job, JobDone, nil)
+}
+
+// update moves a job to a new status and persists it, finished jobs are
+// removed from the active set and have their spooled replay deleted
+func (q *Queue) update(job *Job, status JobStatus, err error) {
+	q.mu.Lock()
+	job.Status = status
+	job.Updated = time.Now().UTC()
+	if err != nil {
+		job.Error = err.Error()
+	}
+	q.mu.Unlock()
+
+	q.save(job)
+
+	if status == JobDone || status == JobFailed {
+		q.untrack(job)
+		if err := os.Remove(job.Path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Could not remove spooled replay [%s]: %s", job.Path, err)
+		}
+	}
+}
+
+func (q *Queue) save(job *Job) {
+	q.mu.Lock()
+	snapshot := *job
+	q.mu.Unlock()
+
+	for host, store := range q.conf.Stores {
+		if err := store.SaveJob(&snapshot); err != nil {
+			log.Printf("Could not save job [%s] to store [%s]. %s", job.ID, host, err)
+		}
+	}
+}
+
+func (q *Queue) track(job *Job) {
+	q.mu.Lock()
+	q.active[job.ID] = job
+	q.mu.Unlock()
+}
+
+func (q *Queue) untrack(job *Job) {
+	q.mu.Lock()
+	delete(q.active, job.ID)
+	q.mu.Unlock()
+}
+
+// unfinishedJobs loads every job that was queued or mid-parse when the
+// server last stopped, jobs are reset to queued and parsed from the start
+func (q *Queue) unfinishedJobs() ([]*Job, error) {
+	filters := map[string]interface{}{
+		"status": []string{string(JobQueued), string(JobParsing), string(JobSaving)},
+	}
+
+	seen := make(map[string]bool)
+	pending := []*Job{}
+	for host, store := range q.conf.Stores {
+		jobs, err := store.LoadJobs(filters)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load jobs from store [%s]: %s", host, err)
+		}
+
+		for i := range jobs {
+			job := jobs[i]
+			if seen[job.ID] {
+				continue
+			}
+			seen[job.ID] = true
+
+			if _, err := os.Stat(job.Path); err != nil {
+				job.Status = JobFailed
+				job.Error = fmt.Sprintf("spooled replay is missing: %s", err)
+				job.Updated = time.Now().UTC()
+				q.save(&job)
+				continue
+			}
+
+			job.Status = JobQueued
+			pending = append(pending, &job)
+		}
+	}
+
+	return pending, nil
+}
+
+// duplicateStore returns the first store which already has a game, or an empty
+// string if none of them do
+func duplicateStore(stores map[string]Store, gameID uint64) (string, error) {
+	for host, store := range stores {
+		info, err := store.LoadReplayInfo(map[string]interface{}{"gameId": []uint64{gameID}})
+		if err != nil {
+			return "", fmt.Errorf("error loading replay [%d] from store [%s]: %s", gameID, host, err)
+		}
+
+		if len(info) >= 1 {
+			return host, nil
+		}
+	}
+
+	return "", nil
+}
+
+// SaveReplay saves a parsed replay to every store, refusing replays which a
+// store has already seen. Each store saves the replay in a single batch, so a
+// store that fails part way through is left without any of it
+func SaveReplay(stores map[string]Store, replay *Replay) error {
+	host, err := duplicateStore(stores, replay.GameID)
+	if err != nil {
+		return err
+	}
+
+	if host != "" {
+		return fmt.Errorf("%s: replay [%d] in store [%s]", ErrDuplicateReplay, replay.GameID, host)
+	}
+
+	var saveErr error
+	for host, store := range stores {
+		if err := saveReplay(store, replay); err != nil {
+			log.Printf("Could not save replay [%d] to store [%s]. %s", replay.GameID, host, err)
+			saveErr = fmt.Errorf("unable to save replay [%d] to store [%s]: %s", replay.GameID, host, err)
+		}
+	}
+
+	return saveErr
+}
+
+// saveReplay saves a replay to one store in a batch, rolling the batch back if
+// any of it can't be saved
+func saveReplay(store Store, replay *Replay) error {
+	batch, err := store.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := saveBatch(batch, replay); err != nil {
+		batch.Rollback()
+		return err
+	}
+
+	return batch.Commit()
+}
+
+// saveBatch saves everything parsed from a replay to a batch
+func saveBatch(batch Batch, replay *Replay) error {
+	for _, purchase := range replay.ItemPurchases {
+		if err := batch.SaveItemPurchase(purchase); err != nil {
+			return fmt.Errorf("unable to save purchase [%+v]: %s", purchase, err)
+		}
+	}
+
+	for _, kill := range replay.Kills {
+		if err := batch.SaveKillEvent(kill); err != nil {
+			return fmt.Errorf("unable to save kill [%+v]: %s", kill, err)
+		}
+	}
+
+	for _, item := range replay.ItemLifecycles {
+		if err := batch.SaveItemLifecycle(item); err != nil {
+			return fmt.Errorf("unable to save item lifecycle [%+v]: %s", item, err)
+		}
+	}
+
+	for _, assembly := range replay.ItemAssemblies {
+		if err := batch.SaveItemAssembly(assembly); err != nil {
+			return fmt.Errorf("unable to save item assembly [%+v]: %s", assembly, err)
+		}
+	}
+
+	for _, sample := range replay.Timeline {
+		if err := batch.SaveTimelineSample(sample); err != nil {
+			return fmt.Errorf("unable to save timeline sample [%+v]: %s", sample, err)
+		}
+	}
+
+	for _, build := range replay.SkillBuilds {
+		if err := batch.SaveSkillBuild(build); err != nil {
+			return fmt.Errorf("unable to save skill build [%s]: %s", build.Hero, err)
+		}
+	}
+
+	for _, ward := range replay.Wards {
+		if err := batch.SaveWard(ward); err != nil {
+			return fmt.Errorf("unable to save ward [%+v]: %s", ward, err)
+		}
+	}
+
+	for _, path := range replay.Paths {
+		if err := batch.SaveHeroPath(path); err != nil {
+			return fmt.Errorf("unable to save path for hero [%s]: %s", path.Hero, err)
+		}
+	}
+
+	for _, objective := range replay.Objectives {
+		if err := batch.SaveObjectiveEvent(objective); err != nil {
+			return fmt.Errorf("unable to save objective event [%+v]: %s", objective, err)
+		}
+	}
+
+	for _, event := range replay.Runes {
+		if err := batch.SaveRuneEvent(event); err != nil {
+			return fmt.Errorf("unable to save rune event [%+v]: %s", event, err)
+		}
+	}
+
+	for _, b := range replay.Buybacks {
+		if err := batch.SaveBuybackEvent(b); err != nil {
+			return fmt.Errorf("unable to save buyback [%+v]: %s", b, err)
+		}
+	}
+
+	for _, spending := range replay.GoldSpending {
+		if err := batch.SaveGoldSpending(spending); err != nil {
+			return fmt.Errorf("unable to save gold spending [%+v]: %s", spending, err)
+		}
+	}
+
+	for _, d := range replay.Damage {
+		if err := batch.SaveDamageAggregate(d); err != nil {
+			return fmt.Errorf("unable to save damage aggregate [%+v]: %s", d, err)
+		}
+	}
+
+	for _, fight := range replay.Fights {
+		if err := batch.SaveTeamfight(fight); err != nil {
+			return fmt.Errorf("unable to save teamfight [%+v]: %s", fight, err)
+		}
+	}
+
+	for _, message := range replay.Chat {
+		if err := batch.SaveChatMessage(message); err != nil {
+			return fmt.Errorf("unable to save chat message [%+v]: %s", message, err)
+		}
+	}
+
+	for _, delivery := range replay.Deliveries {
+		if err := batch.SaveItemDelivery(delivery); err != nil {
+			return fmt.Errorf("unable to save item delivery [%+v]: %s", delivery, err)
+		}
+	}
+
+	for _, snapshot := range replay.Inventories {
+		if err := batch.SaveInventorySnapshot(snapshot); err != nil {
+			return fmt.Errorf("unable to save inventory snapshot [%+v]: %s", snapshot, err)
+		}
+	}
+
+	for _, player := range replay.PlayerInfo {
+		if err := batch.SavePlayerInfo(player); err != nil {
+			return fmt.Errorf("unable to save player info [%+v]: %s", player, err)
+		}
+	}
+
+	for _, slot := range replay.PlayerSlots {
+		if err := batch.SavePlayerSlot(slot); err != nil {
+			return fmt.Errorf("unable to save player slot [%+v]: %s", slot, err)
+		}
+	}
+
+	if err := batch.SaveReplayInfo(replay); err != nil {
+		return fmt.Errorf("unable to save replay info [%d]: %s", replay.GameID, err)
+	}
+
+	return nil
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
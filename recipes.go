@@ -0,0 +1,109 @@
+package secretshop
+
+import (
+	"sort"
+)
+
+// recipes maps a finished item to the sets of items that can be combined to
+// build it, most items have a single set of components but some, like Power
+// Treads, can be built more than one way
+var recipes map[string][][]string
+
+// recipeOrder is the order recipes are checked in, so that assembly detection
+// doesn't depend on map ordering
+var recipeOrder []string
+
+func init() {
+	recipes = make(map[string][][]string)
+
+	recipes["item_abyssal_blade"] = [][]string{{"item_basher", "item_vanguard", "item_recipe_abyssal_blade"}}
+	recipes["item_aether_lens"] = [][]string{{"item_energy_booster", "item_void_stone", "item_recipe_aether_lens"}}
+	recipes["item_ultimate_scepter"] = [][]string{{"item_point_booster", "item_staff_of_wizardry", "item_ogre_axe", "item_blade_of_alacrity"}}
+	recipes["item_arcane_boots"] = [][]string{{"item_boots", "item_energy_booster"}}
+	recipes["item_armlet"] = [][]string{{"item_helm_of_iron_will", "item_gloves", "item_blades_of_attack", "item_recipe_armlet"}}
+	recipes["item_assault"] = [][]string{{"item_platemail", "item_hyperstone", "item_chainmail", "item_recipe_assault"}}
+	recipes["item_bfury"] = [][]string{{"item_demon_edge", "item_quelling_blade", "item_pers"}}
+	recipes["item_black_king_bar"] = [][]string{{"item_ogre_axe", "item_mithril_hammer", "item_recipe_black_king_bar"}}
+	recipes["item_blade_mail"] = [][]string{{"item_broadsword", "item_chainmail", "item_robe"}}
+	recipes["item_bloodstone"] = [][]string{{"item_soul_ring", "item_soul_booster", "item_recipe_bloodstone"}}
+	recipes["item_bloodthorn"] = [][]string{{"item_orchid", "item_lesser_crit", "item_recipe_bloodthorn"}}
+	recipes["item_travel_boots"] = [][]string{{"item_boots", "item_recipe_travel_boots"}}
+	recipes["item_travel_boots_2"] = [][]string{{"item_travel_boots", "item_recipe_travel_boots"}}
+	recipes["item_bracer"] = [][]string{{"item_gauntlets", "item_circlet", "item_recipe_bracer"}}
+	recipes["item_buckler"] = [][]string{{"item_chainmail", "item_branches", "item_recipe_buckler"}}
+	recipes["item_butterfly"] = [][]string{{"item_eagle", "item_talisman_of_evasion", "item_quarterstaff"}}
+	recipes["item_crimson_guard"] = [][]string{{"item_vanguard", "item_buckler", "item_recipe_crimson_guard"}}
+	recipes["item_lesser_crit"] = [][]string{{"item_broadsword", "item_blades_of_attack", "item_recipe_lesser_crit"}}
+	recipes["item_greater_crit"] = [][]string{{"item_lesser_crit", "item_demon_edge", "item_recipe_greater_crit"}}
+	recipes["item_desolator"] = [][]string{{"item_mithril_hammer", "item_mithril_hammer", "item_recipe_desolator"}}
+	recipes["item_diffusal_blade"] = [][]string{{"item_blade_of_alacrity", "item_blade_of_alacrity", "item_robe", "item_recipe_diffusal_blade"}}
+	recipes["item_dragon_lance"] = [][]string{{"item_ogre_axe", "item_boots_of_elves", "item_boots_of_elves"}}
+	recipes["item_ancient_janggo"] = [][]string{{"item_bracer", "item_wind_lace", "item_sobi_mask", "item_recipe_ancient_janggo"}}
+	recipes["item_echo_sabre"] = [][]string{{"item_oblivion_staff", "item_ogre_axe"}}
+	recipes["item_ethereal_blade"] = [][]string{{"item_eagle", "item_ghost"}}
+	recipes["item_cyclone"] = [][]string{{"item_staff_of_wizardry", "item_void_stone", "item_wind_lace", "item_recipe_cyclone"}}
+	recipes["item_skadi"] = [][]string{{"item_ultimate_orb", "item_ultimate_orb", "item_point_booster", "item_orb_of_venom"}}
+	recipes["item_force_staff"] = [][]string{{"item_staff_of_wizardry", "item_ring_of_regen", "item_recipe_force_staff"}}
+	recipes["item_glimmer_cape"] = [][]string{{"item_shadow_amulet", "item_cloak"}}
+	recipes["item_guardian_greaves"] = [][]string{{"item_mekansm", "item_arcane_boots", "item_recipe_guardian_greaves"}}
+	recipes["item_hand_of_midas"] = [][]string{{"item_gloves", "item_recipe_hand_of_midas"}}
+	recipes["item_headdress"] = [][]string{{"item_ring_of_regen", "item_branches", "item_recipe_headdress"}}
+	recipes["item_heart"] = [][]string{{"item_vitality_booster", "item_reaver", "item_recipe_heart"}}
+	recipes["item_heavens_halberd"] = [][]string{{"item_sange", "item_talisman_of_evasion"}}
+	recipes["item_hood_of_defiance"] = [][]string{{"item_ring_of_health", "item_cloak", "item_ring_of_regen"}}
+	recipes["item_hurricane_pike"] = [][]string{{"item_force_staff", "item_dragon_lance", "item_recipe_hurricane_pike"}}
+	recipes["item_sphere"] = [][]string{{"item_ultimate_orb", "item_pers", "item_recipe_sphere"}}
+	recipes["item_lotus_orb"] = [][]string{{"item_pers", "item_platemail", "item_energy_booster"}}
+	recipes["item_maelstrom"] = [][]string{{"item_gloves", "item_mithril_hammer", "item_recipe_maelstrom"}}
+	recipes["item_manta"] = [][]string{{"item_yasha", "item_ultimate_orb", "item_recipe_manta"}}
+	recipes["item_mask_of_madness"] = [][]string{{"item_lifesteal", "item_recipe_mask_of_madness"}}
+	recipes["item_medallion_of_courage"] = [][]string{{"item_chainmail", "item_sobi_mask", "item_blight_stone"}}
+	recipes["item_mekansm"] = [][]string{{"item_headdress", "item_buckler", "item_recipe_mekansm"}}
+	recipes["item_mjollnir"] = [][]string{{"item_maelstrom", "item_hyperstone", "item_recipe_mjollnir"}}
+	recipes["item_monkey_king_bar"] = [][]string{{"item_demon_edge", "item_javelin", "item_javelin"}}
+	recipes["item_moon_shard"] = [][]string{{"item_hyperstone", "item_hyperstone"}}
+	recipes["item_null_talisman"] = [][]string{{"item_mantle", "item_circlet", "item_recipe_null_talisman"}}
+	recipes["item_oblivion_staff"] = [][]string{{"item_quarterstaff", "item_sobi_mask", "item_robe"}}
+	recipes["item_octarine_core"] = [][]string{{"item_mystic_staff", "item_soul_booster"}}
+	recipes["item_orchid"] = [][]string{{"item_oblivion_staff", "item_oblivion_staff", "item_recipe_orchid"}}
+	recipes["item_pers"] = [][]string{{"item_ring_of_health", "item_void_stone"}}
+	recipes["item_phase_boots"] = [][]string{{"item_boots", "item_blades_of_attack", "item_blades_of_attack"}}
+	recipes["item_pipe"] = [][]string{{"item_hood_of_defiance", "item_headdress", "item_recipe_pipe"}}
+	recipes["item_poor_mans_shield"] = [][]string{{"item_slippers", "item_slippers", "item_stout_shield"}}
+	recipes["item_power_treads"] = [][]string{
+		{"item_boots", "item_gloves", "item_belt_of_strength"},
+		{"item_boots", "item_gloves", "item_boots_of_elves"},
+		{"item_boots", "item_gloves", "item_robe"},
+	}
+	recipes["item_radiance"] = [][]string{{"item_relic", "item_recipe_radiance"}}
+	recipes["item_rapier"] = [][]string{{"item_relic", "item_demon_edge"}}
+	recipes["item_refresher"] = [][]string{{"item_pers", "item_pers", "item_recipe_refresher"}}
+	recipes["item_ring_of_aquila"] = [][]string{{"item_wraith_band", "item_ring_of_basilius"}}
+	recipes["item_ring_of_basilius"] = [][]string{{"item_sobi_mask", "item_ring_of_protection"}}
+	recipes["item_sange"] = [][]string{{"item_ogre_axe", "item_belt_of_strength", "item_recipe_sange"}}
+	recipes["item_sange_and_yasha"] = [][]string{{"item_sange", "item_yasha"}}
+	recipes["item_satanic"] = [][]string{{"item_lifesteal", "item_reaver", "item_claymore"}}
+	recipes["item_sheepstick"] = [][]string{{"item_mystic_staff", "item_ultimate_orb", "item_void_stone"}}
+	recipes["item_invis_sword"] = [][]string{{"item_shadow_amulet", "item_claymore"}}
+	recipes["item_silver_edge"] = [][]string{{"item_invis_sword", "item_ultimate_orb", "item_recipe_silver_edge"}}
+	recipes["item_shivas_guard"] = [][]string{{"item_platemail", "item_mystic_staff", "item_recipe_shivas_guard"}}
+	recipes["item_basher"] = [][]string{{"item_javelin", "item_belt_of_strength", "item_recipe_basher"}}
+	recipes["item_soul_booster"] = [][]string{{"item_vitality_booster", "item_energy_booster", "item_point_booster"}}
+	recipes["item_soul_ring"] = [][]string{{"item_ring_of_regen", "item_gauntlets", "item_gauntlets", "item_recipe_soul_ring"}}
+	recipes["item_tranquil_boots"] = [][]string{{"item_boots", "item_wind_lace", "item_ring_of_regen"}}
+	recipes["item_urn_of_shadows"] = [][]string{{"item_gauntlets", "item_gauntlets", "item_sobi_mask", "item_recipe_urn_of_shadows"}}
+	recipes["item_vanguard"] = [][]string{{"item_ring_of_health", "item_vitality_booster", "item_stout_shield"}}
+	recipes["item_wraith_band"] = [][]string{{"item_slippers", "item_circlet", "item_recipe_wraith_band"}}
+	recipes["item_yasha"] = [][]string{{"item_blade_of_alacrity", "item_boots_of_elves", "item_recipe_yasha"}}
+
+	for item := range recipes {
+		recipeOrder = append(recipeOrder, item)
+	}
+	sort.Strings(recipeOrder)
+}
+
+// IsAssembledItem returns true if an item is built from other items
+func IsAssembledItem(item string) bool {
+	_, ok := recipes[item]
+	return ok
+}
@@ -1,7 +1,9 @@
 package secretshop
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/dotabuff/manta"
@@ -10,98 +12,247 @@ import (
 
 // Replay holds information about a replay file
 type Replay struct {
-	File          *os.File          `json:"file,omitempty"`
-	StrategyStart float32           `json:"strategyStart"`
-	GameStart     float32           `json:"gameStart"`
-	GameEnd       float32           `json:"gameEnd"`
-	GameID        uint64            `json:"gameId"`
-	ItemPurchases []*ItemPurchase   `json:"itemPurchases,omitempty"`
-	Players       map[string]uint64 `json:"players"`
-	PlayerInfo    []*PlayerInfo     `json:"playerInfo"`
-	FriendlyName  string            `json:"friendlyName"`
+	StrategyStart  float32              `json:"strategyStart"`
+	GameStart      float32              `json:"gameStart"`
+	GameEnd        float32              `json:"gameEnd"`
+	GameID         uint64               `json:"gameId"`
+	ItemPurchases  []*ItemPurchase      `json:"itemPurchases,omitempty"`
+	Kills          []*KillEvent         `json:"kills,omitempty"`
+	ItemLifecycles []*ItemLifecycle     `json:"itemLifecycles,omitempty"`
+	ItemAssemblies []*ItemAssembly      `json:"itemAssemblies,omitempty"`
+	Timeline       []*TimelineSample    `json:"timeline,omitempty"`
+	Draft          *Draft               `json:"draft,omitempty"`
+	PlayerSlots    []*PlayerSlot        `json:"playerSlots,omitempty"`
+	SkillBuilds    []*SkillBuild        `json:"skillBuilds,omitempty"`
+	Wards          []*Ward              `json:"wards,omitempty"`
+	Paths          []*HeroPath          `json:"paths,omitempty"`
+	Objectives     []*ObjectiveEvent    `json:"objectives,omitempty"`
+	Runes          []*RuneEvent         `json:"runes,omitempty"`
+	Buybacks       []*BuybackEvent      `json:"buybacks,omitempty"`
+	GoldSpending   []*GoldSpending      `json:"goldSpending,omitempty"`
+	Damage         []*DamageAggregate   `json:"damage,omitempty"`
+	Fights         []*Teamfight         `json:"fights,omitempty"`
+	Chat           []*ChatMessage       `json:"chat,omitempty"`
+	Pauses         []*Pause             `json:"pauses,omitempty"`
+	Deliveries     []*ItemDelivery      `json:"deliveries,omitempty"`
+	Inventories    []*InventorySnapshot `json:"inventories,omitempty"`
+	Players        map[string]uint64    `json:"players"`
+	PlayerInfo     []*PlayerInfo        `json:"playerInfo"`
+	FriendlyName   string               `json:"friendlyName"`
+
+	// SampleInterval is how often, in seconds of game time, player economy
+	// data is added to the timeline. Players aren't sampled if it's 0
+	SampleInterval float32 `json:"-"`
+
+	// PositionInterval is how often, in ticks, hero positions are added to
+	// their paths. Positions aren't sampled if it's 0
+	PositionInterval uint32 `json:"-"`
+
+	// InventoryInterval is how often, in seconds of game time, every hero's
+	// items are snapshotted. Snapshots are still taken on deaths and fights
+	// if it's 0
+	InventoryInterval float32 `json:"-"`
+
+	// CaptureChat turns on recording chat messages, which is off by default as
+	// they can contain personal information
+	CaptureChat bool `json:"-"`
+
+	// Modules lists the parser modules to run, every registered module is run
+	// if it's empty
+	Modules []string `json:"-"`
+
+	// Extensions holds anything extracted by modules registered outside of
+	// secretshop, keyed by module name
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+
+	// OnProgress is called periodically while parsing with how far through
+	// the replay the parser has read
+	OnProgress func(ParseProgress) `json:"-"`
+
+	src    io.Reader
+	closer io.Closer
+	size   int64
+	read   int64
+
+	modules []ParserModule
+
+	gameTime         float32
+	snapshots        bool
+	paused           bool
+	nextSample       float32
+	nextPosition     uint32
+	nextInventory    float32
+	snapshotRequests []snapshotRequest
+	playerHeroes     []string
+	goldChanges      []goldChange
+	respawns         []respawn
+	lifeStates       map[int32]int32
+	pendingBuybacks  []*BuybackEvent
+	damage           map[damageKey]*DamageAggregate
+	heroDamage       []heroDamage
+	liveItems        map[int32]*ItemLifecycle
+	groundItems      map[int32]bool
+	droppedItems     map[int32]*ItemLifecycle
+	deliveries       map[*ItemLifecycle]*ItemDelivery
+	playerIDs        map[uint64]int32
+	teams            map[int32]team
+	abilityLevels    map[int32]int32
+	learnt           map[skillKey]bool
+	skillPicks       []*SkillPick
+	liveWards        map[int32]*Ward
+	wardDeaths       []wardDeath
+	paths            map[string]*HeroPath
 }
 
-// NewReplay initializes a replay ready to be parsed
+// ParseProgress reports how far through a replay the parser has got
+type ParseProgress struct {
+	BytesRead  int64  `json:"bytesRead"`
+	TotalBytes int64  `json:"totalBytes,omitempty"`
+	Tick       uint32 `json:"tick"`
+}
+
+// progressInterval is the number of bytes read between progress reports
+const progressInterval = 1024 * 1024
+
+// NewReplay initializes a replay ready to be parsed from a file
 func NewReplay(fileName string) (r *Replay, err error) {
-	r = &Replay{}
-	if r.File, err = os.Open(fileName); err != nil {
+	f, err := os.Open(fileName)
+	if err != nil {
 		return nil, fmt.Errorf("unable to open file: %s", err)
 	}
 
-	r.Players = make(map[string]uint64)
+	r = NewReplayFromReader(f)
+	r.closer = f
+	if info, err := f.Stat(); err == nil {
+		r.size = info.Size()
+	}
 
 	return r, nil
 }
 
+// NewReplayFromReader initializes a replay ready to be parsed from a stream,
+// the reader is not closed once parsing has finished. Replays compressed with
+// bzip2, gzip or zstd are decompressed as they are parsed
+func NewReplayFromReader(src io.Reader) *Replay {
+	return &Replay{
+		Players:           make(map[string]uint64),
+		Extensions:        make(map[string]interface{}),
+		SampleInterval:    DefaultSampleInterval,
+		InventoryInterval: DefaultSampleInterval,
+		src:               src,
+		liveItems:         make(map[int32]*ItemLifecycle),
+		groundItems:       make(map[int32]bool),
+		droppedItems:      make(map[int32]*ItemLifecycle),
+		deliveries:        make(map[*ItemLifecycle]*ItemDelivery),
+		playerIDs:         make(map[uint64]int32),
+		teams:             make(map[int32]team),
+		abilityLevels:     make(map[int32]int32),
+		learnt:            make(map[skillKey]bool),
+		liveWards:         make(map[int32]*Ward),
+		paths:             make(map[string]*HeroPath),
+		lifeStates:        make(map[int32]int32),
+		damage:            make(map[damageKey]*DamageAggregate),
+	}
+}
+
 // Parse reads a replay file and pulls out data from it
 func (r *Replay) Parse() error {
-	defer r.File.Close()
+	return r.ParseContext(context.Background())
+}
 
-	p, err := manta.NewStreamParser(r.File)
-	if err != nil {
-		return fmt.Errorf("unable to create parser: %s", err)
+// ParseContext reads a replay file and pulls out data from it, stopping the
+// parser early if the context is cancelled
+func (r *Replay) ParseContext(ctx context.Context) error {
+	if r.closer != nil {
+		defer r.closer.Close()
 	}
 
-	p.Callbacks.OnCDemoFileInfo(func(m *dota.CDemoFileInfo) error {
-		data := m.GameInfo.GetDota()
-		r.GameID = *data.MatchId
-		for _, player := range data.PlayerInfo {
-			playerInfo := PlayerInfo{
-				SteamID: *player.Steamid,
-				Name:    *player.PlayerName,
-			}
-			r.PlayerInfo = append(r.PlayerInfo, &playerInfo)
-			r.Players[*player.HeroName] = *player.Steamid
-		}
-		return nil
-	})
+	src := &progressReader{ctx: ctx, r: r.src}
+	defer func() {
+		r.read = src.n
+	}()
 
-	p.Callbacks.OnCMsgDOTACombatLogEntry(func(m *dota.CMsgDOTACombatLogEntry) error {
-		t := m.GetType()
+	demo, err := decompress(src)
+	if err != nil {
+		return err
+	}
+	defer demo.Close()
 
-		if t == dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_GAME_STATE {
-			v := m.GetValue()
-			if v == uint32(dota.DOTA_GameState_DOTA_GAMERULES_STATE_GAME_IN_PROGRESS) {
-				r.GameStart = *m.Timestamp
-			} else if v == uint32(dota.DOTA_GameState_DOTA_GAMERULES_STATE_STRATEGY_TIME) {
-				r.StrategyStart = *m.Timestamp
-			} else if v == uint32(dota.DOTA_GameState_DOTA_GAMERULES_STATE_POST_GAME) {
-				r.GameEnd = *m.Timestamp
-			}
+	p, err := manta.NewStreamParser(demo)
+	if err != nil {
+		return fmt.Errorf("unable to create parser: %s", err)
+	}
 
-			return nil
+	var reported int64
+	p.Callbacks.OnCNETMsg_Tick(func(m *dota.CNETMsg_Tick) error {
+		if err := ctx.Err(); err != nil {
+			p.Stop()
+			return err
 		}
 
-		if t == dota.DOTA_COMBATLOG_TYPES_DOTA_COMBATLOG_PURCHASE {
-			item, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetValue()))
-			hero, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetTargetName()))
-			timestamp := m.GetTimestamp()
-
-			purchase := ItemPurchase{
-				Item:      item,
-				Hero:      hero,
-				Timestamp: timestamp,
-				Raw:       m,
-			}
-
-			r.ItemPurchases = append(r.ItemPurchases, &purchase)
-			return nil
+		if r.OnProgress != nil && src.n-reported >= progressInterval {
+			reported = src.n
+			r.OnProgress(ParseProgress{BytesRead: src.n, TotalBytes: r.size, Tick: p.Tick})
 		}
 
 		return nil
 	})
 
+	p.OnEntity(r.parseClock)
+	p.OnEntity(r.parsePause)
+
+	active, err := newModules(r.Modules)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range active {
+		if err := m.Register(p, r); err != nil {
+			return fmt.Errorf("unable to register parser module: %s", err)
+		}
+	}
+	r.modules = active
+
 	if err := p.Start(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if demo.exceeded {
+			return ErrReplayTooLarge
+		}
 		return err
 	}
 
+	if r.OnProgress != nil {
+		r.OnProgress(ParseProgress{BytesRead: src.n, TotalBytes: r.size, Tick: p.Tick})
+	}
+
 	return nil
 }
 
 // Process fills in any missing information from a replay after parsing it
 func (r *Replay) Process() {
-	for _, p := range r.ItemPurchases {
-		p.GameID = r.GameID
-		p.SteamID = r.Players[p.Hero]
+	for _, m := range r.modules {
+		m.Finalize(r)
+	}
+
+	r.processGameTime()
+}
+
+// progressReader counts the bytes handed to the parser and refuses to read
+// any further once its context has been cancelled
+type progressReader struct {
+	ctx context.Context
+	r   io.Reader
+	n   int64
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	if err := pr.ctx.Err(); err != nil {
+		return 0, err
 	}
+
+	n, err := pr.r.Read(b)
+	pr.n += int64(n)
+	return n, err
 }
@@ -0,0 +1,99 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// Things that can happen to a rune
+const (
+	RuneSpawned   = "spawned"
+	RuneActivated = "activated"
+	RuneBottled   = "bottled"
+)
+
+// runeNames maps rune types, as used by rune entities and chat events, to names
+var runeNames = map[int32]string{
+	0: "double_damage",
+	1: "haste",
+	2: "illusion",
+	3: "invisibility",
+	4: "regeneration",
+	5: "bounty",
+	6: "arcane",
+	7: "water",
+	8: "wisdom",
+	9: "shield",
+}
+
+// RuneEvent contains information about a rune spawning or being picked up.
+// Runes picked up are either activated straight away or bottled, spawns have
+// a position but no hero
+type RuneEvent struct {
+	GameID    uint64  `json:"gameId"`
+	Event     string  `json:"event"`
+	Rune      string  `json:"rune"`
+	RuneType  int32   `json:"runeType"`
+	Hero      string  `json:"hero,omitempty"`
+	SteamID   uint64  `json:"steamId,omitempty"`
+	X         float32 `json:"x,omitempty"`
+	Y         float32 `json:"y,omitempty"`
+	Timestamp float32 `json:"timestamp"`
+	GameTime  float32 `json:"gameTime"`
+
+	playerID int32
+}
+
+// parseRuneEntity records runes spawning on the map
+func (r *Replay) parseRuneEntity(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	if e.GetClassName() != "CDOTA_Item_Rune" || !op.Flag(manta.EntityOpCreated) {
+		return nil
+	}
+
+	runeType, _ := e.GetInt32("m_iRuneType")
+	event := &RuneEvent{
+		Event:     RuneSpawned,
+		Rune:      runeNames[runeType],
+		RuneType:  runeType,
+		Timestamp: r.gameTime,
+		playerID:  -1,
+	}
+	event.X, event.Y, _ = entityPosition(e)
+
+	r.Runes = append(r.Runes, event)
+	return nil
+}
+
+// parseRuneEvent records runes being activated or bottled from the chat events
+// announcing them, the hero is looked up from the player id later
+func (r *Replay) parseRuneEvent(m *dota.CDOTAUserMsg_ChatEvent) {
+	var event string
+	switch m.GetType() {
+	case dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_RUNE_PICKUP:
+		event = RuneActivated
+	case dota.DOTA_CHAT_MESSAGE_CHAT_MESSAGE_RUNE_BOTTLE:
+		event = RuneBottled
+	default:
+		return
+	}
+
+	runeType := int32(m.GetValue())
+	r.Runes = append(r.Runes, &RuneEvent{
+		Event:     event,
+		Rune:      runeNames[runeType],
+		RuneType:  runeType,
+		Timestamp: r.gameTime,
+		playerID:  m.GetPlayerid_1(),
+	})
+}
+
+// processRunes fills in the game id and the hero behind each pickup
+func (r *Replay) processRunes() {
+	for _, event := range r.Runes {
+		event.GameID = r.GameID
+		if event.playerID >= 0 {
+			event.Hero = r.heroByPlayerID(event.playerID)
+			event.SteamID = r.Players[event.Hero]
+		}
+	}
+}
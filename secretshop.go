@@ -3,6 +3,7 @@ package secretshop
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 
 	"github.com/BurntSushi/toml"
 )
@@ -11,10 +12,20 @@ var friendlyNames map[string]string
 
 // Config contains details to set up the application
 type Config struct {
-	BindAddress string                  `toml:"bind"`
-	Auth        string                  `toml:"auth"`
-	StoreInfo   map[string]ConfigDBInfo `toml:"stores"`
-	Stores      map[string]Store
+	BindAddress       string                  `toml:"bind"`
+	Auth              string                  `toml:"auth"`
+	Workers           int                     `toml:"workers"`
+	QueueSize         int                     `toml:"queueSize"`
+	SpoolDir          string                  `toml:"spoolDir"`
+	ParseTimeout      int                     `toml:"parseTimeout"`
+	MaxReplayMB       int64                   `toml:"maxReplayMB"`
+	SampleInterval    *int                    `toml:"sampleInterval"`
+	PositionTicks     int                     `toml:"positionTicks"`
+	InventoryInterval *int                    `toml:"inventoryInterval"`
+	CaptureChat       bool                    `toml:"captureChat"`
+	Modules           []string                `toml:"modules"`
+	StoreInfo         map[string]ConfigDBInfo `toml:"stores"`
+	Stores            map[string]Store
 }
 
 // ConfigDBInfo contains details for a database to be used as a store
@@ -26,14 +37,20 @@ type ConfigDBInfo struct {
 	DB      string
 }
 
-// ItemPurchase contains information about an individual item purchase
+// ItemPurchase contains information about an individual item purchase.
+// NearestFight is the number of the teamfight closest to the purchase, or 0 if
+// there were none, and FightOffset how long before (negative) or after
+// (positive) that fight the item was bought
 type ItemPurchase struct {
-	Item      string      `json:"item"`
-	Hero      string      `json:"hero"`
-	GameID    uint64      `json:"gameId"`
-	SteamID   uint64      `json:"steamId"`
-	Timestamp float32     `json:"timestamp"`
-	Raw       interface{} `json:"raw,omitempty"`
+	Item         string      `json:"item"`
+	Hero         string      `json:"hero"`
+	GameID       uint64      `json:"gameId"`
+	SteamID      uint64      `json:"steamId"`
+	Timestamp    float32     `json:"timestamp"`
+	GameTime     float32     `json:"gameTime"`
+	NearestFight int         `json:"nearestFight,omitempty"`
+	FightOffset  float32     `json:"fightOffset,omitempty"`
+	Raw          interface{} `json:"raw,omitempty"`
 }
 
 // PlayerInfo contains information about a player and their team and steam account
@@ -47,11 +64,56 @@ type PlayerInfo struct {
 type Store interface {
 	SaveReplayInfo(*Replay) error
 	SaveReplayInfoFriendlyName(uint64, string) error
-	LoadReplayInfo([]uint64) (map[uint64]Replay, error)
+	LoadReplayInfo(map[string]interface{}) (map[uint64]Replay, error)
 	SavePlayerInfo(*PlayerInfo) error
 	LoadPlayerInfo() (map[uint64]PlayerInfo, error)
+	SavePlayerSlot(*PlayerSlot) error
+	LoadPlayerSlot(map[string]interface{}) ([]PlayerSlot, error)
 	SaveItemPurchase(*ItemPurchase) error
 	LoadItemPurchase(map[string]interface{}) ([]ItemPurchase, error)
+	SaveKillEvent(*KillEvent) error
+	LoadKillEvent(map[string]interface{}) ([]KillEvent, error)
+	SaveItemLifecycle(*ItemLifecycle) error
+	LoadItemLifecycle(map[string]interface{}) ([]ItemLifecycle, error)
+	SaveItemAssembly(*ItemAssembly) error
+	LoadItemAssembly(map[string]interface{}) ([]ItemAssembly, error)
+	SaveTimelineSample(*TimelineSample) error
+	LoadTimeline(map[string]interface{}) ([]TimelineSample, error)
+	SaveSkillBuild(*SkillBuild) error
+	LoadSkillBuild(map[string]interface{}) ([]SkillBuild, error)
+	SaveWard(*Ward) error
+	LoadWard(map[string]interface{}) ([]Ward, error)
+	SaveHeroPath(*HeroPath) error
+	LoadHeroPath(map[string]interface{}) ([]HeroPath, error)
+	SaveObjectiveEvent(*ObjectiveEvent) error
+	LoadObjectiveEvent(map[string]interface{}) ([]ObjectiveEvent, error)
+	SaveRuneEvent(*RuneEvent) error
+	LoadRuneEvent(map[string]interface{}) ([]RuneEvent, error)
+	SaveBuybackEvent(*BuybackEvent) error
+	LoadBuybackEvent(map[string]interface{}) ([]BuybackEvent, error)
+	SaveGoldSpending(*GoldSpending) error
+	LoadGoldSpending(map[string]interface{}) ([]GoldSpending, error)
+	SaveDamageAggregate(*DamageAggregate) error
+	LoadDamageAggregate(map[string]interface{}) ([]DamageAggregate, error)
+	SaveTeamfight(*Teamfight) error
+	LoadTeamfight(map[string]interface{}) ([]Teamfight, error)
+	SaveChatMessage(*ChatMessage) error
+	LoadChatMessage(map[string]interface{}) ([]ChatMessage, error)
+	SaveItemDelivery(*ItemDelivery) error
+	LoadItemDelivery(map[string]interface{}) ([]ItemDelivery, error)
+	SaveInventorySnapshot(*InventorySnapshot) error
+	LoadInventorySnapshot(map[string]interface{}) ([]InventorySnapshot, error)
+	SaveJob(*Job) error
+	LoadJobs(map[string]interface{}) ([]Job, error)
+	Begin() (Batch, error)
+}
+
+// Batch is a store whose saves are only written once Commit is called, so a
+// replay is either saved whole or not at all
+type Batch interface {
+	Store
+	Commit() error
+	Rollback() error
 }
 
 func init() {
@@ -433,9 +495,44 @@ func ReadConfig(confFile string) (c Config, err error) {
 
 	c.Stores = make(map[string]Store)
 
+	if c.Workers <= 0 {
+		c.Workers = 2
+	}
+
+	if c.QueueSize <= 0 {
+		c.QueueSize = 32
+	}
+
+	if c.SpoolDir == "" {
+		c.SpoolDir = os.TempDir()
+	}
+
+	if err := CheckModules(c.Modules); err != nil {
+		return c, err
+	}
+
 	return c, nil
 }
 
+// Configure applies the parser settings from a config to a replay. Intervals
+// left out of the config keep the replay's defaults, and 0 turns them off
+func (c Config) Configure(r *Replay) {
+	if c.SampleInterval != nil {
+		r.SampleInterval = float32(*c.SampleInterval)
+	}
+
+	if c.PositionTicks > 0 {
+		r.PositionInterval = uint32(c.PositionTicks)
+	}
+
+	if c.InventoryInterval != nil {
+		r.InventoryInterval = float32(*c.InventoryInterval)
+	}
+
+	r.CaptureChat = c.CaptureChat
+	r.Modules = c.Modules
+}
+
 // GetFriendlyName returns a friendly name from an internal name
 func GetFriendlyName(internalName string) (friendlyName string, err error) {
 	if _, ok := friendlyNames[internalName]; !ok {
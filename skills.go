@@ -0,0 +1,110 @@
+package secretshop
+
+import (
+	"strings"
+
+	"github.com/dotabuff/manta"
+)
+
+// SkillBuild contains the order a player levelled their abilities in
+type SkillBuild struct {
+	GameID  uint64       `json:"gameId"`
+	SteamID uint64       `json:"steamId"`
+	Hero    string       `json:"hero"`
+	Skills  []*SkillPick `json:"skills"`
+}
+
+// SkillPick contains information about a single ability level being learnt
+type SkillPick struct {
+	Order        int     `json:"order"`
+	Ability      string  `json:"ability"`
+	AbilityLevel int32   `json:"abilityLevel"`
+	HeroLevel    int32   `json:"heroLevel"`
+	Timestamp    float32 `json:"timestamp"`
+	GameTime     float32 `json:"gameTime"`
+
+	hero string
+}
+
+// parseAbilityEntity records a skill pick whenever an ability owned by a hero
+// goes up a level. Abilities that are already levelled when they are created
+// aren't learnt by the player, so only increases after that are counted
+func (r *Replay) parseAbilityEntity(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	class := e.GetClassName()
+	if !strings.HasPrefix(class, "CDOTA_Ability_") && class != "CDOTABaseAbility" {
+		return nil
+	}
+
+	index := e.GetIndex()
+	if op.Flag(manta.EntityOpDeleted) {
+		delete(r.abilityLevels, index)
+		return nil
+	}
+
+	level, ok := e.GetInt32("m_iLevel")
+	if !ok {
+		return nil
+	}
+
+	last, seen := r.abilityLevels[index]
+	r.abilityLevels[index] = level
+	if !seen || level <= last {
+		return nil
+	}
+
+	owner := entityOwner(p, e)
+	if !isHero(owner) {
+		return nil
+	}
+
+	hero := entityName(p, owner)
+	ability := entityName(p, e)
+	heroLevel, _ := owner.GetInt32("m_iCurrentLevel")
+
+	for l := last + 1; l <= level; l++ {
+		// Illusions and clones level their abilities alongside the real hero
+		key := skillKey{hero: hero, ability: ability, level: l}
+		if r.learnt[key] {
+			continue
+		}
+		r.learnt[key] = true
+
+		r.skillPicks = append(r.skillPicks, &SkillPick{
+			Ability:      ability,
+			AbilityLevel: l,
+			HeroLevel:    heroLevel,
+			Timestamp:    r.gameTime,
+			hero:         hero,
+		})
+	}
+
+	return nil
+}
+
+// skillKey identifies a single level of an ability for a hero
+type skillKey struct {
+	hero    string
+	ability string
+	level   int32
+}
+
+// processSkillBuilds groups skill picks into a build for each hero
+func (r *Replay) processSkillBuilds() {
+	builds := make(map[string]*SkillBuild)
+	for _, pick := range r.skillPicks {
+		build, ok := builds[pick.hero]
+		if !ok {
+			build = &SkillBuild{
+				GameID:  r.GameID,
+				SteamID: r.Players[pick.hero],
+				Hero:    pick.hero,
+				Skills:  []*SkillPick{},
+			}
+			builds[pick.hero] = build
+			r.SkillBuilds = append(r.SkillBuilds, build)
+		}
+
+		pick.Order = len(build.Skills)
+		build.Skills = append(build.Skills, pick)
+	}
+}
@@ -2,8 +2,8 @@ package mysql
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"strings"
 
 	"strconv"
@@ -20,13 +20,28 @@ type processedReplay struct {
 	Players       string
 	Heroes        string
 	FriendlyName  string
+	Draft         secretshop.Draft
 }
 
 // Store implementation of secretshop.Store
 type Store struct {
 	db *sql.DB
+
+	// batch is only set on stores returned by Begin
+	batch *batch
+}
+
+// batch holds the transaction behind a secretshop.Batch, along with one
+// prepared statement for each query run in it
+type batch struct {
+	tx    *sql.Tx
+	stmts map[string]*sql.Stmt
 }
 
+// errNoBatch is returned when a store is committed or rolled back without
+// having been returned by Begin
+var errNoBatch = errors.New("store is not a batch")
+
 // NewStore handles creating a store and connecting to a database with information
 // from a config file
 func NewStore(c *secretshop.Config, data secretshop.ConfigDBInfo) (err error) {
@@ -36,7 +51,7 @@ func NewStore(c *secretshop.Config, data secretshop.ConfigDBInfo) (err error) {
 		connString = strings.Join([]string{data.Address, connPort}, ":")
 	}
 
-	connInfo := fmt.Sprintf("%s:%s@tcp(%s)/%s", data.User, data.Pass, connString, data.DB)
+	connInfo := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", data.User, data.Pass, connString, data.DB)
 	db, err := sql.Open("mysql", connInfo)
 	if err != nil {
 		return err
@@ -53,102 +68,706 @@ func NewStore(c *secretshop.Config, data secretshop.ConfigDBInfo) (err error) {
 	return nil
 }
 
+// exec runs an insert or update. Inside a batch each query is only prepared
+// once, however many rows it saves
+func (s Store) exec(query string, args ...interface{}) error {
+	if s.batch == nil {
+		_, err := s.db.Exec(query, args...)
+		return err
+	}
+
+	stmt, ok := s.batch.stmts[query]
+	if !ok {
+		var err error
+		stmt, err = s.batch.tx.Prepare(query)
+		if err != nil {
+			return err
+		}
+		s.batch.stmts[query] = stmt
+	}
+
+	_, err := stmt.Exec(args...)
+	return err
+}
+
+// Begin implementation for secretshop.Store
+func (s Store) Begin() (secretshop.Batch, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return Store{db: s.db, batch: &batch{tx: tx, stmts: make(map[string]*sql.Stmt)}}, nil
+}
+
+// Commit implementation for secretshop.Batch, statements prepared in the
+// batch are closed along with the transaction
+func (s Store) Commit() error {
+	if s.batch == nil {
+		return errNoBatch
+	}
+
+	return s.batch.tx.Commit()
+}
+
+// Rollback implementation for secretshop.Batch
+func (s Store) Rollback() error {
+	if s.batch == nil {
+		return errNoBatch
+	}
+
+	return s.batch.tx.Rollback()
+}
+
 // SaveItemPurchase implementation for secretshop.Store
 func (s Store) SaveItemPurchase(i *secretshop.ItemPurchase) error {
-	stmt, err := s.db.Prepare("INSERT item_purchase SET gameId=?,steamId=?,hero=?,item=?,timestamp=?")
+	return s.exec("INSERT item_purchase SET gameId=?,steamId=?,hero=?,item=?,timestamp=?,gameTime=?,nearestFight=?,fightOffset=?",
+		i.GameID, i.SteamID, i.Hero, i.Item, i.Timestamp, i.GameTime, i.NearestFight, i.FightOffset)
+}
+
+// LoadItemPurchase implementation for secretshop.Store
+func (s Store) LoadItemPurchase(filters map[string]interface{}) (i []secretshop.ItemPurchase, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "item", "item")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, steamId, hero, item, timestamp, gameTime, nearestFight, fightOffset FROM item_purchase")
+	rows, err := s.db.Query(query, c.args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer stmt.Close()
+	defer rows.Close()
 
-	if _, err := stmt.Exec(i.GameID, i.SteamID, i.Hero, i.Item, i.Timestamp); err != nil {
-		return err
+	for rows.Next() {
+		var (
+			gameID       uint64
+			steamID      uint64
+			hero         string
+			item         string
+			timestamp    float32
+			gameTime     float32
+			nearestFight int
+			fightOffset  float32
+		)
+		err := rows.Scan(&gameID, &steamID, &hero, &item, &timestamp, &gameTime, &nearestFight, &fightOffset)
+		if err != nil {
+			return nil, err
+		}
+		purchase := secretshop.ItemPurchase{
+			GameID:       gameID,
+			SteamID:      steamID,
+			Hero:         hero,
+			Item:         item,
+			Timestamp:    timestamp,
+			GameTime:     gameTime,
+			NearestFight: nearestFight,
+			FightOffset:  fightOffset,
+		}
+		i = append(i, purchase)
+	}
+
+	return i, nil
+}
+
+// SaveKillEvent implementation for secretshop.Store
+func (s Store) SaveKillEvent(k *secretshop.KillEvent) error {
+	return s.exec("INSERT kill_event SET gameId=?,killer=?,killerSteamId=?,victim=?,victimSteamId=?,assisters=?,timestamp=?,gameTime=?,buybackEligible=?,goldLost=?,deathDuration=?",
+		k.GameID, k.Killer, k.KillerSteamID, k.Victim, k.VictimSteamID, strings.Join(k.Assisters, ","), k.Timestamp, k.GameTime, k.BuybackEligible, k.GoldLost, k.DeathDuration)
+}
+
+// LoadKillEvent implementation for secretshop.Store
+func (s Store) LoadKillEvent(filters map[string]interface{}) (k []secretshop.KillEvent, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.anyIn(filters, "player", "killerSteamId", "victimSteamId")
+	c.anyIn(filters, "hero", "killer", "victim")
+	c.in(filters, "killer", "killer")
+	c.in(filters, "victim", "victim")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, killer, killerSteamId, victim, victimSteamId, assisters, timestamp, gameTime, buybackEligible, goldLost, deathDuration FROM kill_event")
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			kill      secretshop.KillEvent
+			assisters string
+		)
+		if err := rows.Scan(&kill.GameID, &kill.Killer, &kill.KillerSteamID, &kill.Victim, &kill.VictimSteamID, &assisters, &kill.Timestamp, &kill.GameTime, &kill.BuybackEligible, &kill.GoldLost, &kill.DeathDuration); err != nil {
+			return nil, err
+		}
+
+		kill.Assisters = []string{}
+		if assisters != "" {
+			kill.Assisters = strings.Split(assisters, ",")
+		}
+		k = append(k, kill)
+	}
+
+	return k, nil
+}
+
+// SaveItemLifecycle implementation for secretshop.Store
+func (s Store) SaveItemLifecycle(i *secretshop.ItemLifecycle) error {
+	return s.exec("INSERT item_lifecycle SET gameId=?,steamId=?,hero=?,item=?,acquired=?,gameTime=?,uses=?,removed=?,removedBy=?",
+		i.GameID, i.SteamID, i.Hero, i.Item, i.Acquired, i.GameTime, i.Uses, i.Removed, i.RemovedBy)
+}
+
+// LoadItemLifecycle implementation for secretshop.Store
+func (s Store) LoadItemLifecycle(filters map[string]interface{}) (i []secretshop.ItemLifecycle, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "item", "item")
+	c.in(filters, "removedBy", "removedBy")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, steamId, hero, item, acquired, gameTime, uses, removed, removedBy FROM item_lifecycle")
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item secretshop.ItemLifecycle
+		if err := rows.Scan(&item.GameID, &item.SteamID, &item.Hero, &item.Item, &item.Acquired, &item.GameTime, &item.Uses, &item.Removed, &item.RemovedBy); err != nil {
+			return nil, err
+		}
+		i = append(i, item)
+	}
+
+	return i, nil
+}
+
+// SaveItemAssembly implementation for secretshop.Store
+func (s Store) SaveItemAssembly(a *secretshop.ItemAssembly) error {
+	return s.exec("INSERT item_assembly SET gameId=?,steamId=?,hero=?,item=?,timestamp=?,gameTime=?,components=?",
+		a.GameID, a.SteamID, a.Hero, a.Item, a.Timestamp, a.GameTime, strings.Join(a.Components, ","))
+}
+
+// LoadItemAssembly implementation for secretshop.Store
+func (s Store) LoadItemAssembly(filters map[string]interface{}) (a []secretshop.ItemAssembly, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "item", "item")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, steamId, hero, item, timestamp, gameTime, components FROM item_assembly") + " ORDER BY timestamp"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			assembly   secretshop.ItemAssembly
+			components string
+		)
+		if err := rows.Scan(&assembly.GameID, &assembly.SteamID, &assembly.Hero, &assembly.Item, &assembly.Timestamp, &assembly.GameTime, &components); err != nil {
+			return nil, err
+		}
+		assembly.Components = strings.Split(components, ",")
+		a = append(a, assembly)
+	}
+
+	return a, nil
+}
+
+// SaveTimelineSample implementation for secretshop.Store
+func (s Store) SaveTimelineSample(t *secretshop.TimelineSample) error {
+	return s.exec("INSERT timeline SET gameId=?,steamId=?,hero=?,timestamp=?,gameTime=?,gold=?,netWorth=?,xp=?,level=?,lastHits=?,denies=?",
+		t.GameID, t.SteamID, t.Hero, t.Timestamp, t.GameTime, t.Gold, t.NetWorth, t.XP, t.Level, t.LastHits, t.Denies)
+}
+
+// LoadTimeline implementation for secretshop.Store
+func (s Store) LoadTimeline(filters map[string]interface{}) (t []secretshop.TimelineSample, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, steamId, hero, timestamp, gameTime, gold, netWorth, xp, level, lastHits, denies FROM timeline") + " ORDER BY timestamp"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sample secretshop.TimelineSample
+		if err := rows.Scan(&sample.GameID, &sample.SteamID, &sample.Hero, &sample.Timestamp, &sample.GameTime, &sample.Gold, &sample.NetWorth, &sample.XP, &sample.Level, &sample.LastHits, &sample.Denies); err != nil {
+			return nil, err
+		}
+		t = append(t, sample)
+	}
+
+	return t, nil
+}
+
+// SaveSkillBuild implementation for secretshop.Store
+func (s Store) SaveSkillBuild(b *secretshop.SkillBuild) error {
+	for _, p := range b.Skills {
+		if err := s.exec("INSERT skill_build SET gameId=?,steamId=?,hero=?,skillOrder=?,ability=?,abilityLevel=?,heroLevel=?,timestamp=?,gameTime=?",
+			b.GameID, b.SteamID, b.Hero, p.Order, p.Ability, p.AbilityLevel, p.HeroLevel, p.Timestamp, p.GameTime); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// LoadItemPurchase implementation for secretshop.Store
-func (s Store) LoadItemPurchase(filters map[string]interface{}) (i []secretshop.ItemPurchase, err error) {
-	query := "SELECT * FROM item_purchase"
-	conditions := []string{}
-	args := []interface{}{}
+// LoadSkillBuild implementation for secretshop.Store
+func (s Store) LoadSkillBuild(filters map[string]interface{}) (b []secretshop.SkillBuild, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "ability", "ability")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, steamId, hero, skillOrder, ability, abilityLevel, heroLevel, timestamp, gameTime FROM skill_build") + " ORDER BY gameId, hero, skillOrder"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	if gameIDs, ok := filters["gameId"]; ok {
-		vars := make([]string, len(gameIDs.([]uint64)))
-		for i, gameID := range gameIDs.([]uint64) {
-			vars[i] = "?"
-			args = append(args, gameID)
+	for rows.Next() {
+		var (
+			gameID  uint64
+			steamID uint64
+			hero    string
+			pick    secretshop.SkillPick
+		)
+		if err := rows.Scan(&gameID, &steamID, &hero, &pick.Order, &pick.Ability, &pick.AbilityLevel, &pick.HeroLevel, &pick.Timestamp, &pick.GameTime); err != nil {
+			return nil, err
+		}
+
+		if len(b) == 0 || b[len(b)-1].GameID != gameID || b[len(b)-1].Hero != hero {
+			b = append(b, secretshop.SkillBuild{
+				GameID:  gameID,
+				SteamID: steamID,
+				Hero:    hero,
+				Skills:  []*secretshop.SkillPick{},
+			})
 		}
-		gameList := strings.Join(vars, ",")
-		conditions = append(conditions, "gameId IN ("+gameList+")")
+		build := &b[len(b)-1]
+		build.Skills = append(build.Skills, &pick)
 	}
 
-	if players, ok := filters["player"]; ok {
-		vars := make([]string, len(players.([]uint64)))
-		for i, player := range players.([]uint64) {
-			vars[i] = "?"
-			args = append(args, player)
+	return b, nil
+}
+
+// SaveWard implementation for secretshop.Store
+func (s Store) SaveWard(w *secretshop.Ward) error {
+	return s.exec("INSERT ward SET gameId=?,type=?,hero=?,steamId=?,team=?,x=?,y=?,placed=?,gameTime=?,removed=?,lifetime=?,killedBy=?,dewarded=?",
+		w.GameID, w.Type, w.Hero, w.SteamID, w.Team, w.X, w.Y, w.Placed, w.GameTime, w.Removed, w.Lifetime, w.KilledBy, w.Dewarded)
+}
+
+// LoadWard implementation for secretshop.Store
+func (s Store) LoadWard(filters map[string]interface{}) (w []secretshop.Ward, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "type", "type")
+	c.in(filters, "team", "team")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, type, hero, steamId, team, x, y, placed, gameTime, removed, lifetime, killedBy, dewarded FROM ward") + " ORDER BY gameId, placed"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ward secretshop.Ward
+		if err := rows.Scan(&ward.GameID, &ward.Type, &ward.Hero, &ward.SteamID, &ward.Team, &ward.X, &ward.Y, &ward.Placed, &ward.GameTime, &ward.Removed, &ward.Lifetime, &ward.KilledBy, &ward.Dewarded); err != nil {
+			return nil, err
 		}
-		playerList := strings.Join(vars, ",")
-		conditions = append(conditions, "steamId IN ("+playerList+")")
+		w = append(w, ward)
 	}
 
-	if heroes, ok := filters["hero"]; ok {
-		vars := make([]string, len(heroes.([]string)))
-		for i, hero := range heroes.([]string) {
-			vars[i] = "?"
-			args = append(args, hero)
+	return w, nil
+}
+
+// SaveHeroPath implementation for secretshop.Store, points are stored packed
+// into a single blob per hero
+func (s Store) SaveHeroPath(h *secretshop.HeroPath) error {
+	return s.exec("INSERT hero_path SET gameId=?,steamId=?,hero=?,points=?",
+		h.GameID, h.SteamID, h.Hero, secretshop.EncodePath(h.Points))
+}
+
+// LoadHeroPath implementation for secretshop.Store
+func (s Store) LoadHeroPath(filters map[string]interface{}) (h []secretshop.HeroPath, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+
+	query := c.apply("SELECT gameId, steamId, hero, points FROM hero_path") + " ORDER BY gameId"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var path secretshop.HeroPath
+		var points []byte
+		if err := rows.Scan(&path.GameID, &path.SteamID, &path.Hero, &points); err != nil {
+			return nil, err
+		}
+
+		if path.Points, err = secretshop.DecodePath(points); err != nil {
+			return nil, err
 		}
-		heroList := strings.Join(vars, ",")
-		conditions = append(conditions, "hero IN ("+heroList+")")
+		h = append(h, path)
+	}
+
+	return h, nil
+}
+
+// SaveObjectiveEvent implementation for secretshop.Store
+func (s Store) SaveObjectiveEvent(o *secretshop.ObjectiveEvent) error {
+	return s.exec("INSERT objective_event SET gameId=?,type=?,target=?,hero=?,steamId=?,team=?,timestamp=?,gameTime=?",
+		o.GameID, o.Type, o.Target, o.Hero, o.SteamID, o.Team, o.Timestamp, o.GameTime)
+}
+
+// LoadObjectiveEvent implementation for secretshop.Store
+func (s Store) LoadObjectiveEvent(filters map[string]interface{}) (o []secretshop.ObjectiveEvent, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "type", "type")
+	c.in(filters, "team", "team")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, type, target, hero, steamId, team, timestamp, gameTime FROM objective_event") + " ORDER BY gameId, timestamp"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	if items, ok := filters["item"]; ok {
-		vars := make([]string, len(items.([]string)))
-		for i, item := range items.([]string) {
-			vars[i] = "?"
-			args = append(args, item)
+	for rows.Next() {
+		var event secretshop.ObjectiveEvent
+		if err := rows.Scan(&event.GameID, &event.Type, &event.Target, &event.Hero, &event.SteamID, &event.Team, &event.Timestamp, &event.GameTime); err != nil {
+			return nil, err
 		}
-		itemList := strings.Join(vars, ",")
-		conditions = append(conditions, "item IN ("+itemList+")")
+		o = append(o, event)
 	}
 
-	var rows *sql.Rows
-	if len(filters) > 0 {
-		where := strings.Join(conditions, " AND ")
-		query = strings.Join([]string{query, where}, " WHERE ")
-		rows, err = s.db.Query(query, args...)
-		if err != nil {
+	return o, nil
+}
+
+// SaveRuneEvent implementation for secretshop.Store
+func (s Store) SaveRuneEvent(e *secretshop.RuneEvent) error {
+	return s.exec("INSERT rune_event SET gameId=?,event=?,rune=?,runeType=?,hero=?,steamId=?,x=?,y=?,timestamp=?,gameTime=?",
+		e.GameID, e.Event, e.Rune, e.RuneType, e.Hero, e.SteamID, e.X, e.Y, e.Timestamp, e.GameTime)
+}
+
+// LoadRuneEvent implementation for secretshop.Store
+func (s Store) LoadRuneEvent(filters map[string]interface{}) (e []secretshop.RuneEvent, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "rune", "rune")
+	c.in(filters, "event", "event")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, event, rune, runeType, hero, steamId, x, y, timestamp, gameTime FROM rune_event") + " ORDER BY gameId, timestamp"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event secretshop.RuneEvent
+		if err := rows.Scan(&event.GameID, &event.Event, &event.Rune, &event.RuneType, &event.Hero, &event.SteamID, &event.X, &event.Y, &event.Timestamp, &event.GameTime); err != nil {
 			return nil, err
 		}
-	} else {
-		rows, err = s.db.Query(query)
-		if err != nil {
+		e = append(e, event)
+	}
+
+	return e, nil
+}
+
+// SaveBuybackEvent implementation for secretshop.Store
+func (s Store) SaveBuybackEvent(b *secretshop.BuybackEvent) error {
+	return s.exec("INSERT buyback_event SET gameId=?,hero=?,steamId=?,timestamp=?,gameTime=?,cost=?,goldAfter=?,nextItem=?,nextItemCost=?,couldAffordNextItem=?",
+		b.GameID, b.Hero, b.SteamID, b.Timestamp, b.GameTime, b.Cost, b.GoldAfter, b.NextItem, b.NextItemCost, b.CouldAffordNextItem)
+}
+
+// LoadBuybackEvent implementation for secretshop.Store
+func (s Store) LoadBuybackEvent(filters map[string]interface{}) (b []secretshop.BuybackEvent, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, hero, steamId, timestamp, gameTime, cost, goldAfter, nextItem, nextItemCost, couldAffordNextItem FROM buyback_event") + " ORDER BY gameId, timestamp"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event secretshop.BuybackEvent
+		if err := rows.Scan(&event.GameID, &event.Hero, &event.SteamID, &event.Timestamp, &event.GameTime, &event.Cost, &event.GoldAfter, &event.NextItem, &event.NextItemCost, &event.CouldAffordNextItem); err != nil {
 			return nil, err
 		}
+		b = append(b, event)
+	}
+
+	return b, nil
+}
+
+// SaveGoldSpending implementation for secretshop.Store
+func (s Store) SaveGoldSpending(g *secretshop.GoldSpending) error {
+	return s.exec("INSERT gold_spending SET gameId=?,steamId=?,hero=?,items=?,consumables=?,buybacks=?,deaths=?,total=?",
+		g.GameID, g.SteamID, g.Hero, g.Items, g.Consumables, g.Buybacks, g.Deaths, g.Total)
+}
+
+// LoadGoldSpending implementation for secretshop.Store
+func (s Store) LoadGoldSpending(filters map[string]interface{}) (g []secretshop.GoldSpending, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+
+	query := c.apply("SELECT gameId, steamId, hero, items, consumables, buybacks, deaths, total FROM gold_spending")
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var spending secretshop.GoldSpending
+		if err := rows.Scan(&spending.GameID, &spending.SteamID, &spending.Hero, &spending.Items, &spending.Consumables, &spending.Buybacks, &spending.Deaths, &spending.Total); err != nil {
+			return nil, err
+		}
+		g = append(g, spending)
+	}
+
+	return g, nil
+}
+
+// SaveDamageAggregate implementation for secretshop.Store
+func (s Store) SaveDamageAggregate(d *secretshop.DamageAggregate) error {
+	return s.exec("INSERT damage SET gameId=?,steamId=?,hero=?,kind=?,inflictor=?,target=?,amount=?,hits=?",
+		d.GameID, d.SteamID, d.Hero, d.Kind, d.Inflictor, d.Target, d.Amount, d.Hits)
+}
+
+// LoadDamageAggregate implementation for secretshop.Store
+func (s Store) LoadDamageAggregate(filters map[string]interface{}) (d []secretshop.DamageAggregate, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "kind", "kind")
+	c.in(filters, "inflictor", "inflictor")
+	c.in(filters, "target", "target")
+
+	query := c.apply("SELECT gameId, steamId, hero, kind, inflictor, target, amount, hits FROM damage")
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var aggregate secretshop.DamageAggregate
+		if err := rows.Scan(&aggregate.GameID, &aggregate.SteamID, &aggregate.Hero, &aggregate.Kind, &aggregate.Inflictor, &aggregate.Target, &aggregate.Amount, &aggregate.Hits); err != nil {
+			return nil, err
+		}
+		d = append(d, aggregate)
+	}
+
+	return d, nil
+}
+
+// SaveTeamfight implementation for secretshop.Store
+func (s Store) SaveTeamfight(f *secretshop.Teamfight) error {
+	return s.exec("INSERT teamfight SET gameId=?,number=?,start=?,gameTime=?,end=?,participants=?,deaths=?,radiantDeaths=?,direDeaths=?,goldSwing=?",
+		f.GameID, f.Number, f.Start, f.GameTime, f.End, strings.Join(f.Participants, ","), strings.Join(f.Deaths, ","), f.RadiantDeaths, f.DireDeaths, f.GoldSwing)
+}
+
+// LoadTeamfight implementation for secretshop.Store
+func (s Store) LoadTeamfight(filters map[string]interface{}) (f []secretshop.Teamfight, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "number", "number")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, number, start, gameTime, end, participants, deaths, radiantDeaths, direDeaths, goldSwing FROM teamfight") + " ORDER BY gameId, number"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var (
-			gameID    uint64
-			steamID   uint64
-			hero      string
-			item      string
-			timestamp float32
+			fight        secretshop.Teamfight
+			participants string
+			deaths       string
 		)
-		err := rows.Scan(&gameID, &steamID, &hero, &item, &timestamp)
-		if err != nil {
+		if err := rows.Scan(&fight.GameID, &fight.Number, &fight.Start, &fight.GameTime, &fight.End, &participants, &deaths, &fight.RadiantDeaths, &fight.DireDeaths, &fight.GoldSwing); err != nil {
 			return nil, err
 		}
-		purchase := secretshop.ItemPurchase{
-			GameID:    gameID,
-			SteamID:   steamID,
-			Hero:      hero,
-			Item:      item,
-			Timestamp: timestamp,
+
+		fight.Participants = []string{}
+		if participants != "" {
+			fight.Participants = strings.Split(participants, ",")
 		}
-		i = append(i, purchase)
+		fight.Deaths = []string{}
+		if deaths != "" {
+			fight.Deaths = strings.Split(deaths, ",")
+		}
+		f = append(f, fight)
+	}
+
+	return f, nil
+}
+
+// SaveChatMessage implementation for secretshop.Store
+func (s Store) SaveChatMessage(m *secretshop.ChatMessage) error {
+	return s.exec("INSERT chat_message SET gameId=?,steamId=?,sender=?,hero=?,channel=?,message=?,wheelId=?,timestamp=?,gameTime=?",
+		m.GameID, m.SteamID, m.Sender, m.Hero, m.Channel, m.Message, m.WheelID, m.Timestamp, m.GameTime)
+}
+
+// LoadChatMessage implementation for secretshop.Store
+func (s Store) LoadChatMessage(filters map[string]interface{}) (m []secretshop.ChatMessage, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "channel", "channel")
+	c.contains(filters, "search", "message")
+
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, steamId, sender, hero, channel, message, wheelId, timestamp, gameTime FROM chat_message") + " ORDER BY gameId, timestamp"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message secretshop.ChatMessage
+		if err := rows.Scan(&message.GameID, &message.SteamID, &message.Sender, &message.Hero, &message.Channel, &message.Message, &message.WheelID, &message.Timestamp, &message.GameTime); err != nil {
+			return nil, err
+		}
+		m = append(m, message)
+	}
+
+	return m, nil
+}
+
+// SaveItemDelivery implementation for secretshop.Store
+func (s Store) SaveItemDelivery(d *secretshop.ItemDelivery) error {
+	return s.exec("INSERT item_delivery SET gameId=?,steamId=?,hero=?,item=?,purchased=?,gameTime=?,stashed=?,pickedUp=?,delivered=?,latency=?,byCourier=?",
+		d.GameID, d.SteamID, d.Hero, d.Item, d.Purchased, d.GameTime, d.Stashed, d.PickedUp, d.Delivered, d.Latency, d.ByCourier)
+}
+
+// LoadItemDelivery implementation for secretshop.Store
+func (s Store) LoadItemDelivery(filters map[string]interface{}) (d []secretshop.ItemDelivery, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "item", "item")
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, steamId, hero, item, purchased, gameTime, stashed, pickedUp, delivered, latency, byCourier FROM item_delivery") + " ORDER BY gameId, purchased"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery secretshop.ItemDelivery
+		if err := rows.Scan(&delivery.GameID, &delivery.SteamID, &delivery.Hero, &delivery.Item, &delivery.Purchased, &delivery.GameTime, &delivery.Stashed, &delivery.PickedUp, &delivery.Delivered, &delivery.Latency, &delivery.ByCourier); err != nil {
+			return nil, err
+		}
+		d = append(d, delivery)
+	}
+
+	return d, nil
+}
+
+// SaveInventorySnapshot implementation for secretshop.Store
+func (s Store) SaveInventorySnapshot(i *secretshop.InventorySnapshot) error {
+	return s.exec("INSERT inventory_snapshot SET gameId=?,steamId=?,hero=?,reason=?,timestamp=?,gameTime=?,inventory=?,backpack=?,stash=?,teleport=?,neutral=?",
+		i.GameID, i.SteamID, i.Hero, i.Reason, i.Timestamp, i.GameTime,
+		strings.Join(i.Inventory, ","), strings.Join(i.Backpack, ","), strings.Join(i.Stash, ","), i.Teleport, i.Neutral)
+}
+
+// LoadInventorySnapshot implementation for secretshop.Store
+func (s Store) LoadInventorySnapshot(filters map[string]interface{}) (i []secretshop.InventorySnapshot, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "reason", "reason")
+	c.between(filters, "gameTime")
+
+	query := c.apply("SELECT gameId, steamId, hero, reason, timestamp, gameTime, inventory, backpack, stash, teleport, neutral FROM inventory_snapshot") + " ORDER BY gameId, timestamp"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			snapshot                   secretshop.InventorySnapshot
+			inventory, backpack, stash string
+		)
+		if err := rows.Scan(&snapshot.GameID, &snapshot.SteamID, &snapshot.Hero, &snapshot.Reason, &snapshot.Timestamp, &snapshot.GameTime,
+			&inventory, &backpack, &stash, &snapshot.Teleport, &snapshot.Neutral); err != nil {
+			return nil, err
+		}
+
+		// Empty slots are kept, so splitting always gives back every slot
+		snapshot.Inventory = strings.Split(inventory, ",")
+		snapshot.Backpack = strings.Split(backpack, ",")
+		snapshot.Stash = strings.Split(stash, ",")
+		i = append(i, snapshot)
 	}
 
 	return i, nil
@@ -157,43 +776,49 @@ func (s Store) LoadItemPurchase(filters map[string]interface{}) (i []secretshop.
 // SaveReplayInfo implementation for secretshop.Store
 func (s Store) SaveReplayInfo(r *secretshop.Replay) error {
 	p := processReplay(r)
-	stmt, err := s.db.Prepare("INSERT replay_info SET gameId=?,strategyStart=?,gameStart=?,gameEnd=?,players=?,heroes=?")
-	if err != nil {
+	d := p.Draft
+	if err := s.exec("INSERT replay_info SET gameId=?,strategyStart=?,gameStart=?,gameEnd=?,players=?,heroes=?,"+
+		"gameMode=?,winner=?,leagueId=?,radiantTeamId=?,direTeamId=?,radiantTeamTag=?,direTeamTag=?,endTime=?",
+		p.GameID, p.StrategyStart, p.GameStart, p.GameEnd, p.Players, p.Heroes,
+		d.GameMode, d.Winner, d.LeagueID, d.RadiantTeamID, d.DireTeamID, d.RadiantTeamTag, d.DireTeamTag, d.EndTime); err != nil {
 		return err
 	}
-	defer stmt.Close()
 
-	if _, err := stmt.Exec(p.GameID, p.StrategyStart, p.GameStart, p.GameEnd, p.Players, p.Heroes); err != nil {
-		return err
+	for _, selection := range d.PicksBans {
+		if err := s.exec("INSERT draft_selection SET gameId=?,pickOrder=?,isPick=?,team=?,heroId=?",
+			p.GameID, selection.Order, selection.IsPick, selection.Team, selection.HeroID); err != nil {
+			return err
+		}
+	}
+
+	for _, pause := range r.Pauses {
+		if err := s.exec("INSERT replay_pause SET gameId=?,start=?,end=?,gameTime=?",
+			p.GameID, pause.Start, pause.End, pause.GameTime); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 // LoadReplayInfo implementation for secretshop.Store
-func (s Store) LoadReplayInfo(gameIDs []uint64) (map[uint64]secretshop.Replay, error) {
+func (s Store) LoadReplayInfo(filters map[string]interface{}) (map[uint64]secretshop.Replay, error) {
 	r := secretshop.Replay{}
-	vars := make([]string, len(gameIDs))
-	args := make([]interface{}, len(gameIDs))
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "gameMode", "gameMode")
+	c.in(filters, "winner", "winner")
+	c.in(filters, "leagueId", "leagueId")
+	c.anyIn(filters, "team", "radiantTeamId", "direTeamId")
 
-	for i := 0; i < len(gameIDs); i++ {
-		vars[i] = "?"
-		args[i] = gameIDs[i]
-	}
-
-	query := "SELECT * FROM replay_info"
-	if len(gameIDs) > 0 {
-		query = "SELECT * FROM replay_info WHERE gameId IN (" + strings.Join(vars, ",") + ")"
-	}
+	query := c.apply("SELECT gameId, strategyStart, gameStart, gameEnd, players, heroes, friendlyName, " +
+		"gameMode, winner, leagueId, radiantTeamId, direTeamId, radiantTeamTag, direTeamTag, endTime FROM replay_info")
 
-	log.Printf("GameIds: %+v", gameIDs)
-	log.Printf("Query: %s", query)
-	log.Printf("Args: %+v", args)
-
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.Query(query, c.args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	replays := make(map[uint64]secretshop.Replay)
 	for rows.Next() {
@@ -204,9 +829,13 @@ func (s Store) LoadReplayInfo(gameIDs []uint64) (map[uint64]secretshop.Replay, e
 			gameEnd       float32
 			players       string
 			heroes        string
-			friendlyName  string
+			friendlyName  sql.NullString
+			draft         secretshop.Draft
 		)
-		rows.Scan(&id, &strategyStart, &gameStart, &gameEnd, &players, &heroes, &friendlyName)
+		if err := rows.Scan(&id, &strategyStart, &gameStart, &gameEnd, &players, &heroes, &friendlyName,
+			&draft.GameMode, &draft.Winner, &draft.LeagueID, &draft.RadiantTeamID, &draft.DireTeamID, &draft.RadiantTeamTag, &draft.DireTeamTag, &draft.EndTime); err != nil {
+			return nil, err
+		}
 		playerInfo := strings.Split(players, ",")
 		heroInfo := strings.Split(heroes, ",")
 		r.GameID = id
@@ -214,7 +843,7 @@ func (s Store) LoadReplayInfo(gameIDs []uint64) (map[uint64]secretshop.Replay, e
 		r.GameStart = gameStart
 		r.GameEnd = gameEnd
 		r.Players = make(map[string]uint64)
-		r.FriendlyName = friendlyName
+		r.FriendlyName = friendlyName.String
 		for i := 0; i < len(playerInfo); i++ {
 			player, err := strconv.ParseUint(playerInfo[i], 10, 64)
 			if err != nil {
@@ -223,42 +852,110 @@ func (s Store) LoadReplayInfo(gameIDs []uint64) (map[uint64]secretshop.Replay, e
 
 			r.Players[heroInfo[i]] = player
 		}
+
+		draft.PicksBans = []secretshop.DraftSelection{}
+		r.Draft = &draft
 		replays[id] = r
 	}
 
+	if err := s.loadDraftSelections(replays); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadPauses(replays); err != nil {
+		return nil, err
+	}
+
 	return replays, nil
 }
 
-// SaveReplayInfoFriendlyName implementation for secretshop.Store
-func (s Store) SaveReplayInfoFriendlyName(gameID uint64, friendlyName string) error {
-	stmt, err := s.db.Prepare("UPDATE replay_info SET friendlyName=? WHERE gameId=?")
+// loadDraftSelections fills in the picks and bans for a set of replays
+func (s Store) loadDraftSelections(replays map[uint64]secretshop.Replay) error {
+	if len(replays) == 0 {
+		return nil
+	}
+
+	gameIDs := []uint64{}
+	for id := range replays {
+		gameIDs = append(gameIDs, id)
+	}
+
+	c := conditions{}
+	c.in(map[string]interface{}{"gameId": gameIDs}, "gameId", "gameId")
+	query := c.apply("SELECT gameId, pickOrder, isPick, team, heroId FROM draft_selection") + " ORDER BY gameId, pickOrder"
+
+	rows, err := s.db.Query(query, c.args...)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer rows.Close()
 
-	if _, err := stmt.Exec(friendlyName, gameID); err != nil {
-		return err
+	for rows.Next() {
+		var (
+			id        uint64
+			selection secretshop.DraftSelection
+		)
+		if err := rows.Scan(&id, &selection.Order, &selection.IsPick, &selection.Team, &selection.HeroID); err != nil {
+			return err
+		}
+
+		draft := replays[id].Draft
+		draft.PicksBans = append(draft.PicksBans, selection)
 	}
 
 	return nil
 }
 
-// SavePlayerInfo implementation for secretshop.Store
-func (s Store) SavePlayerInfo(p *secretshop.PlayerInfo) error {
-	stmt, err := s.db.Prepare("INSERT player_info SET steamId=?,team=?,name=?")
+// loadPauses fills in the pauses for a set of replays
+func (s Store) loadPauses(replays map[uint64]secretshop.Replay) error {
+	if len(replays) == 0 {
+		return nil
+	}
+
+	gameIDs := []uint64{}
+	for id := range replays {
+		gameIDs = append(gameIDs, id)
+	}
+
+	c := conditions{}
+	c.in(map[string]interface{}{"gameId": gameIDs}, "gameId", "gameId")
+	query := c.apply("SELECT gameId, start, end, gameTime FROM replay_pause") + " ORDER BY gameId, start"
+
+	rows, err := s.db.Query(query, c.args...)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer rows.Close()
 
-	if _, err := stmt.Exec(p.SteamID, p.Team, p.Name); err != nil {
-		return err
+	for rows.Next() {
+		var (
+			id    uint64
+			pause secretshop.Pause
+		)
+		if err := rows.Scan(&id, &pause.Start, &pause.End, &pause.GameTime); err != nil {
+			return err
+		}
+
+		replay := replays[id]
+		replay.Pauses = append(replay.Pauses, &pause)
+		replays[id] = replay
 	}
 
 	return nil
 }
 
+// SaveReplayInfoFriendlyName implementation for secretshop.Store
+func (s Store) SaveReplayInfoFriendlyName(gameID uint64, friendlyName string) error {
+	return s.exec("UPDATE replay_info SET friendlyName=? WHERE gameId=?",
+		friendlyName, gameID)
+}
+
+// SavePlayerInfo implementation for secretshop.Store
+func (s Store) SavePlayerInfo(p *secretshop.PlayerInfo) error {
+	return s.exec("INSERT player_info SET steamId=?,team=?,name=? ON DUPLICATE KEY UPDATE team=VALUES(team),name=VALUES(name)",
+		p.SteamID, p.Team, p.Name)
+}
+
 // LoadPlayerInfo implementation for secretshop.Store
 func (s Store) LoadPlayerInfo() (p map[uint64]secretshop.PlayerInfo, err error) {
 	playerInfo := make(map[uint64]secretshop.PlayerInfo)
@@ -290,6 +987,80 @@ func (s Store) LoadPlayerInfo() (p map[uint64]secretshop.PlayerInfo, err error)
 	return playerInfo, nil
 }
 
+// SaveJob implementation for secretshop.Store
+func (s Store) SaveJob(j *secretshop.Job) error {
+	return s.exec("INSERT replay_job SET id=?,fileName=?,path=?,status=?,gameId=?,modules=?,error=?,created=?,updated=? "+
+		"ON DUPLICATE KEY UPDATE status=VALUES(status),gameId=VALUES(gameId),error=VALUES(error),updated=VALUES(updated)",
+		j.ID, j.FileName, j.Path, string(j.Status), j.GameID, strings.Join(j.Modules, ","), j.Error, j.Created, j.Updated)
+}
+
+// LoadJobs implementation for secretshop.Store
+func (s Store) LoadJobs(filters map[string]interface{}) (j []secretshop.Job, err error) {
+	c := conditions{}
+	c.in(filters, "id", "id")
+	c.in(filters, "status", "status")
+
+	query := c.apply("SELECT id, fileName, path, status, gameId, modules, error, created, updated FROM replay_job")
+	query += " ORDER BY created"
+
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			job     secretshop.Job
+			status  string
+			modules string
+		)
+		if err := rows.Scan(&job.ID, &job.FileName, &job.Path, &status, &job.GameID, &modules, &job.Error, &job.Created, &job.Updated); err != nil {
+			return nil, err
+		}
+		job.Status = secretshop.JobStatus(status)
+		if modules != "" {
+			job.Modules = strings.Split(modules, ",")
+		}
+		j = append(j, job)
+	}
+
+	return j, rows.Err()
+}
+
+// SavePlayerSlot implementation for secretshop.Store
+func (s Store) SavePlayerSlot(p *secretshop.PlayerSlot) error {
+	return s.exec("INSERT player_slot SET gameId=?,steamId=?,playerId=?,hero=?,side=?,teamId=?,teamTag=?,teamName=?",
+		p.GameID, p.SteamID, p.PlayerID, p.Hero, p.Side, p.TeamID, p.TeamTag, p.TeamName)
+}
+
+// LoadPlayerSlot implementation for secretshop.Store
+func (s Store) LoadPlayerSlot(filters map[string]interface{}) (p []secretshop.PlayerSlot, err error) {
+	c := conditions{}
+	c.in(filters, "gameId", "gameId")
+	c.in(filters, "player", "steamId")
+	c.in(filters, "hero", "hero")
+	c.in(filters, "side", "side")
+	c.in(filters, "team", "teamId")
+
+	query := c.apply("SELECT gameId, steamId, playerId, hero, side, teamId, teamTag, teamName FROM player_slot") + " ORDER BY gameId, playerId"
+	rows, err := s.db.Query(query, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var slot secretshop.PlayerSlot
+		if err := rows.Scan(&slot.GameID, &slot.SteamID, &slot.PlayerID, &slot.Hero, &slot.Side, &slot.TeamID, &slot.TeamTag, &slot.TeamName); err != nil {
+			return nil, err
+		}
+		p = append(p, slot)
+	}
+
+	return p, nil
+}
+
 func processReplay(r *secretshop.Replay) (p processedReplay) {
 	p = processedReplay{
 		GameID:        r.GameID,
@@ -309,5 +1080,8 @@ func processReplay(r *secretshop.Replay) (p processedReplay) {
 
 	p.Heroes = strings.Join(heroes, ",")
 	p.Players = strings.Join(players, ",")
+	if r.Draft != nil {
+		p.Draft = *r.Draft
+	}
 	return p
 }
@@ -0,0 +1,94 @@
+package mysql
+
+import (
+	"strings"
+)
+
+// conditions builds up the WHERE clause of a query from request filters
+type conditions struct {
+	where []string
+	args  []interface{}
+}
+
+// in adds a "column IN (...)" condition for a filter if it has been set
+func (c *conditions) in(filters map[string]interface{}, key string, column string) {
+	c.anyIn(filters, key, column)
+}
+
+// anyIn adds a condition matching a filter against any of several columns
+func (c *conditions) anyIn(filters map[string]interface{}, key string, columns ...string) {
+	filter, ok := filters[key]
+	if !ok {
+		return
+	}
+
+	values := []interface{}{}
+	switch v := filter.(type) {
+	case []uint64:
+		for _, value := range v {
+			values = append(values, value)
+		}
+	case []string:
+		for _, value := range v {
+			values = append(values, value)
+		}
+	}
+
+	if len(values) == 0 {
+		return
+	}
+
+	vars := make([]string, len(values))
+	for i := range values {
+		vars[i] = "?"
+	}
+	list := strings.Join(vars, ",")
+
+	clauses := make([]string, len(columns))
+	for i, column := range columns {
+		clauses[i] = column + " IN (" + list + ")"
+		c.args = append(c.args, values...)
+	}
+
+	if len(clauses) == 1 {
+		c.where = append(c.where, clauses[0])
+		return
+	}
+	c.where = append(c.where, "("+strings.Join(clauses, " OR ")+")")
+}
+
+// contains adds a "column LIKE ..." condition matching a filter anywhere in a
+// column if it has been set
+func (c *conditions) contains(filters map[string]interface{}, key string, column string) {
+	filter, ok := filters[key].(string)
+	if !ok || filter == "" {
+		return
+	}
+
+	escaped := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(filter)
+	c.where = append(c.where, column+" LIKE ?")
+	c.args = append(c.args, "%"+escaped+"%")
+}
+
+// between adds conditions keeping a column within the from and to filters if
+// they have been set
+func (c *conditions) between(filters map[string]interface{}, column string) {
+	if from, ok := filters["from"].(float32); ok {
+		c.where = append(c.where, column+" >= ?")
+		c.args = append(c.args, from)
+	}
+
+	if to, ok := filters["to"].(float32); ok {
+		c.where = append(c.where, column+" <= ?")
+		c.args = append(c.args, to)
+	}
+}
+
+// apply adds the WHERE clause to a query
+func (c *conditions) apply(query string) string {
+	if len(c.where) == 0 {
+		return query
+	}
+
+	return strings.Join([]string{query, strings.Join(c.where, " AND ")}, " WHERE ")
+}
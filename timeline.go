@@ -0,0 +1,121 @@
+package secretshop
+
+import (
+	"fmt"
+
+	"github.com/dotabuff/manta"
+)
+
+// DefaultSampleInterval is how often, in seconds of game time, player economy
+// data is sampled if a replay doesn't say otherwise
+const DefaultSampleInterval = 60
+
+// TimelineSample contains a player's economy at a point in the game
+type TimelineSample struct {
+	GameID    uint64  `json:"gameId"`
+	SteamID   uint64  `json:"steamId"`
+	Hero      string  `json:"hero"`
+	Timestamp float32 `json:"timestamp"`
+	GameTime  float32 `json:"gameTime"`
+	Gold      int32   `json:"gold"`
+	NetWorth  int32   `json:"netWorth"`
+	XP        int32   `json:"xp"`
+	Level     int32   `json:"level"`
+	LastHits  int32   `json:"lastHits"`
+	Denies    int32   `json:"denies"`
+}
+
+// parseTimeline samples every player's economy each time the game clock
+// passes the sample interval
+func (r *Replay) parseTimeline(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	if e.GetClassName() != "CDOTAGamerulesProxy" || r.SampleInterval <= 0 {
+		return nil
+	}
+
+	if r.gameTime < r.nextSample {
+		return nil
+	}
+
+	samples := r.samplePlayers(p)
+	if len(samples) == 0 {
+		return nil
+	}
+
+	r.Timeline = append(r.Timeline, samples...)
+	r.nextSample = r.gameTime + r.SampleInterval
+	return nil
+}
+
+// samplePlayers reads the current economy of every player from the player
+// resource and team data entities
+func (r *Replay) samplePlayers(p *manta.Parser) []*TimelineSample {
+	pr := findEntity(p, "CDOTA_PlayerResource")
+	radiant := findEntity(p, "CDOTA_DataRadiant")
+	dire := findEntity(p, "CDOTA_DataDire")
+	if pr == nil || radiant == nil || dire == nil {
+		return nil
+	}
+
+	samples := []*TimelineSample{}
+	for i := 0; i < maxPlayers; i++ {
+		steamID, ok := pr.GetUint64(fmt.Sprintf("m_vecPlayerData.%04d.m_iPlayerSteamID", i))
+		if !ok || steamID == 0 {
+			continue
+		}
+
+		prop, ok := teamData(pr, radiant, dire, i)
+		if !ok {
+			continue
+		}
+
+		sample := &TimelineSample{
+			SteamID:   steamID,
+			Timestamp: r.gameTime,
+			Gold:      prop("m_iReliableGold") + prop("m_iUnreliableGold"),
+			NetWorth:  prop("m_iNetWorth"),
+			XP:        prop("m_iTotalEarnedXP"),
+			LastHits:  prop("m_iLastHitCount"),
+			Denies:    prop("m_iDenyCount"),
+		}
+		sample.Level, _ = pr.GetInt32(fmt.Sprintf("m_vecPlayerTeamData.%04d.m_iLevel", i))
+
+		if handle, ok := pr.GetUint32(fmt.Sprintf("m_vecPlayerTeamData.%04d.m_hSelectedHero", i)); ok {
+			if hero := p.FindEntityByHandle(uint64(handle)); hero != nil {
+				sample.Hero = entityName(p, hero)
+			}
+		}
+
+		samples = append(samples, sample)
+	}
+
+	return samples
+}
+
+// teamData returns a function reading a player's props from the team data
+// entity for their side, which holds their gold, net worth and farm
+func teamData(pr, radiant, dire *manta.Entity, playerID int) (func(string) int32, bool) {
+	team, _ := pr.GetInt32(fmt.Sprintf("m_vecPlayerData.%04d.m_iPlayerTeam", playerID))
+	data := radiant
+	if team == teamDire {
+		data = dire
+	} else if team != teamRadiant {
+		return nil, false
+	}
+
+	slot, ok := pr.GetInt32(fmt.Sprintf("m_vecPlayerTeamData.%04d.m_iTeamSlot", playerID))
+	if !ok {
+		slot = int32(playerID % 5)
+	}
+
+	return func(name string) int32 {
+		v, _ := data.GetInt32(fmt.Sprintf("m_vecDataTeam.%04d.%s", slot, name))
+		return v
+	}, true
+}
+
+// processTimeline fills in the game id for each sample
+func (r *Replay) processTimeline() {
+	for _, s := range r.Timeline {
+		s.GameID = r.GameID
+	}
+}
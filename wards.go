@@ -0,0 +1,133 @@
+package secretshop
+
+import (
+	"github.com/dotabuff/manta"
+	"github.com/dotabuff/manta/dota"
+)
+
+// Types of ward
+const (
+	WardObserver = "observer"
+	WardSentry   = "sentry"
+)
+
+// wardClasses maps ward entity classes to their type
+var wardClasses = map[string]string{
+	"CDOTA_NPC_Observer_Ward":           WardObserver,
+	"CDOTA_NPC_Observer_Ward_TrueSight": WardSentry,
+}
+
+// wardUnits maps the combat log names of wards to their type
+var wardUnits = map[string]string{
+	"npc_dota_observer_wards": WardObserver,
+	"npc_dota_sentry_wards":   WardSentry,
+}
+
+// Ward contains information about a ward from being placed until it expired
+// or was killed
+type Ward struct {
+	GameID   uint64  `json:"gameId"`
+	Type     string  `json:"type"`
+	Hero     string  `json:"hero"`
+	SteamID  uint64  `json:"steamId"`
+	Team     int32   `json:"team"`
+	X        float32 `json:"x"`
+	Y        float32 `json:"y"`
+	Placed   float32 `json:"placed"`
+	GameTime float32 `json:"gameTime"`
+	Removed  float32 `json:"removed,omitempty"`
+	Lifetime float32 `json:"lifetime,omitempty"`
+	KilledBy string  `json:"killedBy,omitempty"`
+	Dewarded bool    `json:"dewarded"`
+}
+
+// wardDeath is a ward being killed in the combat log, matched up with ward
+// entities once parsing has finished
+type wardDeath struct {
+	Type      string
+	Killer    string
+	Timestamp float32
+}
+
+// parseWardEntity follows ward entities from being placed until they're removed
+func (r *Replay) parseWardEntity(p *manta.Parser, e *manta.Entity, op manta.EntityOp) error {
+	wardType, ok := wardClasses[e.GetClassName()]
+	if !ok {
+		return nil
+	}
+
+	index := e.GetIndex()
+	if op.Flag(manta.EntityOpCreated) {
+		ward := &Ward{
+			Type:   wardType,
+			Placed: r.gameTime,
+		}
+		ward.Team, _ = e.GetInt32("m_iTeamNum")
+		ward.X, ward.Y, _ = entityPosition(e)
+		if owner := entityOwner(p, e); isHero(owner) {
+			ward.Hero = entityName(p, owner)
+		}
+
+		r.Wards = append(r.Wards, ward)
+		r.liveWards[index] = ward
+		return nil
+	}
+
+	if op.Flag(manta.EntityOpDeleted) {
+		if ward, ok := r.liveWards[index]; ok {
+			ward.Removed = r.gameTime
+			ward.Lifetime = ward.Removed - ward.Placed
+			delete(r.liveWards, index)
+		}
+	}
+
+	return nil
+}
+
+// parseWardDeath records a ward being killed from the combat log
+func (r *Replay) parseWardDeath(p *manta.Parser, m *dota.CMsgDOTACombatLogEntry) {
+	target, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetTargetName()))
+	wardType, ok := wardUnits[target]
+	if !ok {
+		return
+	}
+
+	killer, _ := p.LookupStringByIndex("CombatLogNames", int32(m.GetAttackerName()))
+	r.wardDeaths = append(r.wardDeaths, wardDeath{
+		Type:      wardType,
+		Killer:    killer,
+		Timestamp: m.GetTimestamp(),
+	})
+}
+
+// processWards matches ward deaths to wards and works out whether the ward
+// was killed by the enemy team or denied by its own
+func (r *Replay) processWards() {
+	sides := make(map[string]int32)
+	for _, slot := range r.PlayerSlots {
+		sides[slot.Hero] = slot.gameTeam
+	}
+
+	matched := make(map[int]bool)
+	for _, ward := range r.Wards {
+		ward.GameID = r.GameID
+		ward.SteamID = r.Players[ward.Hero]
+
+		if ward.Removed == 0 {
+			continue
+		}
+
+		for i, death := range r.wardDeaths {
+			if matched[i] || death.Type != ward.Type || abs32(death.Timestamp-ward.Removed) > sameMoment {
+				continue
+			}
+			matched[i] = true
+
+			ward.KilledBy = death.Killer
+			if side, ok := sides[death.Killer]; ok {
+				ward.Dewarded = side != ward.Team
+			}
+			break
+		}
+	}
+}